@@ -7,10 +7,12 @@ package smpp
 // Imports
 import (
 	"os"
+	"io"
 	"net"
+	"sync"
 	"bufio"
 	"strconv"
-	"fmt"
+	"strings"
 )
 
 // Used for all outbound connections
@@ -22,10 +24,51 @@ type smpp struct {
 	bound		bool
 	async		bool
 	sequence	uint32
+	mutex		sync.Mutex
+	window		int
+	inflight	chan bool
+	pending		map[uint32]chan *Response
+	reading		bool
+	done		chan bool
+	readErr		os.Error
+	onResponse	ResponseHandler
+	deliverSM	DeliverSMHandler
+	dataSM		DataSMHandler
+	interval	int64
+	timeout		int64
+	linkDead	func()
+	keepaliveStop	chan bool
+	host		string
+	port		int
+	bindCmd		SMPPCommand
+	bindRespCmd	SMPPCommand
+	bindParams	*BindParams
+	state		SMPPState
+	stateChange	StateHandler
+	supervised	bool
+	minDelay	int64
+	maxDelay	int64
+	closed		chan bool
+	closing		bool
+	onRequest	func(rpdu PDU)
+	autoCoding	bool
+	autoLatin1	bool
+	segmentation	SMPPSegmentation
+	msgRef		uint16
+	reassemble	bool
+	reassemblyTimeout	int64
+	reassemblyStop	chan bool
+	concat		map[concatKey]*concatSet
+	version		uint8
+	scVersion	uint8
 }
 
 // Connect to server
 func (smpp *smpp) connect(host string, port int) (err os.Error) {
+	// Store host and port for reconnecting
+	smpp.host = host
+	smpp.port = port
+	smpp.setState(STATE_CONNECTING)
 	// Create TCP connection
 	smpp.conn, err = net.Dial("tcp", "", host + ":" + strconv.Itoa(port))
 	if err != nil {
@@ -46,52 +89,74 @@ func (smpp *smpp) close() (err os.Error) {
 }
 
 // Send bind request (called via NewTransmitter/NewReceiver/NewTransceiver) always synchronous
-func (smpp *smpp) bind(cmd, rcmd SMPPCommand, params Params) (err os.Error) {
-	// Sequence number starts at 1
-	smpp.sequence ++
+func (smpp *smpp) bind(cmd, rcmd SMPPCommand, params *BindParams) (err os.Error) {
+	// Use defaults if no params
+	if params == nil {
+		params = NewBindParams()
+	}
+	// Validate params
+	err = params.Validate()
+	if err != nil {
+		return
+	}
+	// Transceiver was added in v3.4
+	if params.InterfaceVersion == SMPP_INTERFACE_VER_33 && cmd == CMD_BIND_TRANSCEIVER {
+		err = os.NewError("Bind: Transceiver bind is not supported by interface version 3.3")
+		return
+	}
+	// Store bind for rebinding
+	smpp.bindCmd     = cmd
+	smpp.bindRespCmd = rcmd
+	smpp.bindParams  = params
+	smpp.segmentation = params.Segmentation
 	// PDU header
 	hdr := new(PDUHeader)
-	hdr.CmdLength = 23 // Min length
 	hdr.CmdId     = cmd
 	hdr.CmdStatus = STATUS_ESME_ROK
-	hdr.Sequence  = smpp.sequence
 	// Create bind PDU
 	pdu := new(PDUBind)
-	// Mising params cause panic, this provides a clean error/exit
-	paramOK := false
-	defer func() {
-		if !paramOK && recover() != nil {
-			err = os.NewError("Bind: Panic, invalid params")
-			return
-		}
-	}()
 	// Populate params
-	pdu.SystemId     = params["systemId"].(string)
-	pdu.Password     = params["password"].(string)
-	pdu.SystemType   = params["systemType"].(string)
-	pdu.IfVersion    = SMPP_INTERFACE_VER
-	pdu.AddrTon      = params["addrTon"].(SMPPTypeOfNumber)
-	pdu.AddrNpi      = params["addrNpi"].(SMPPNumericPlanIndicator)
-	pdu.AddressRange = params["addressRange"].(string)
-	// Add length of strings to pdu length
-	hdr.CmdLength += uint32(len(pdu.SystemId))
-	hdr.CmdLength += uint32(len(pdu.Password))
-	hdr.CmdLength += uint32(len(pdu.SystemType))
-	hdr.CmdLength += uint32(len(pdu.AddressRange))
-	// Params were fine 'disable' the recover
-	paramOK = true
-	// Send PDU
+	pdu.SystemId     = params.SystemId
+	pdu.Password     = params.Password
+	pdu.SystemType   = params.SystemType
+	pdu.IfVersion    = params.InterfaceVersion
+	pdu.AddrTon      = params.AddrTon
+	pdu.AddrNpi      = params.AddrNpi
+	pdu.AddressRange = params.AddressRange
+	if pdu.IfVersion == 0 {
+		pdu.IfVersion = SMPP_INTERFACE_VER
+	}
+	// Send PDU and get response (sequence number starts at 1)
 	pdu.setHeader(hdr)
-	err = pdu.write(smpp.writer)
+	rpdu, err := smpp.request(pdu, rcmd)
 	if err != nil {
 		return
 	}
-	// Get response
-	_, err = smpp.GetResp(rcmd, smpp.sequence)
+	smpp.version = pdu.IfVersion
+	// SMSC version is in the response, if not present the SMSC is v3.3 and doesn't support optional params
+	smpp.scVersion = SMPP_INTERFACE_VER_33
+	if resp, ok := rpdu.(*PDUBindResp); ok {
+		if ver, ok := resp.Optional[TAG_SC_INTERFACE_VERSION].(uint8); ok {
+			smpp.scVersion = ver
+		}
+	}
 	return
 }
 
-// Set async commands on/offer (trancsceiver is always async)
+// Get the interface version negotiated on bind, the lower of the ESME and SMSC versions
+func (smpp *smpp) InterfaceVersion() uint8 {
+	if smpp.scVersion < smpp.version {
+		return smpp.scVersion
+	}
+	return smpp.version
+}
+
+// Get the SMSC interface version reported on bind
+func (smpp *smpp) SCInterfaceVersion() uint8 {
+	return smpp.scVersion
+}
+
+// Set async commands on/off, responses to async commands are passed to the response handler
 func (smpp *smpp) Async(async bool) {
 	smpp.async = async
 }
@@ -103,117 +168,324 @@ func (smpp *smpp) Unbind() (sequence uint32, err os.Error) {
 		err = os.NewError("Unbind: A bound connection is required to unbind")
 		return
 	}
-	// Increment sequence number
-	smpp.sequence ++
+	smpp.setClosing()
+	smpp.setState(STATE_UNBINDING)
 	// PDU header
 	hdr := new(PDUHeader)
-	hdr.CmdLength = 16
 	hdr.CmdId     = CMD_UNBIND
 	hdr.CmdStatus = STATUS_ESME_ROK
-	hdr.Sequence  = smpp.sequence
 	// Create bind PDU
 	pdu := new(PDUUnbind)
 	pdu.setHeader(hdr)
-	// Send PDU
-	err = pdu.write(smpp.writer)
-	if err != nil {
-		return
-	}
-	// If not async get the response
+	// If async send the PDU and return the sequence number, else get the response
 	if smpp.async {
-		sequence = smpp.sequence
+		sequence, err = smpp.sendRequest(pdu)
 	} else {
-		_, err = smpp.GetResp(CMD_UNBIND_RESP, smpp.sequence)
+		_, err = smpp.request(pdu, CMD_UNBIND_RESP)
 	}
 	return
 }
 
-// Get response PDU 
+// Get response PDU, requests received while waiting are answered and responses to other requests skipped
 func (smpp *smpp) GetResp(cmd SMPPCommand, sequence uint32) (rpdu PDU, err os.Error) {
-	// Read the header
-	hdr := new(PDUHeader)
-	err = hdr.read(smpp.reader)
-	fmt.Printf("Header: %#v\n", hdr)
+	for {
+		// Read the PDU, requests that can't be decoded have been nacked
+		var hdr *PDUHeader
+		var p []byte
+		hdr, p, err = smpp.readRaw()
+		if err != nil {
+			return nil, err
+		}
+		rpdu, err = smpp.decode(hdr, p)
+		if err != nil && hdr.CmdId & 0x80000000 == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		smpp.normaliseIds(rpdu)
+		// Answer enquire link and unbind from the SMSC
+		if hdr.CmdId & 0x80000000 == 0 {
+			smpp.handleRequest(rpdu)
+			if !smpp.connected {
+				err = os.NewError("Get Response: Unbound by SMSC")
+				return nil, err
+			}
+			continue
+		}
+		// Skip responses to other requests, a generic nack without a sequence couldn't be matched by the SMSC
+		if sequence > 0 && hdr.Sequence != sequence && !(hdr.CmdId == CMD_GENERIC_NACK && hdr.Sequence == 0) {
+			continue
+		}
+		// Generic nack fails the request whatever the status
+		if hdr.CmdId == CMD_GENERIC_NACK {
+			err = newSMPPError(hdr)
+			return nil, err
+		}
+		// Check cmd if not 0
+		if cmd != CMD_NONE && hdr.CmdId != cmd {
+			err = os.NewError("Get Response: Invalid command")
+			return nil, err
+		}
+		// Check for error response, the PDU is returned with the error as it may contain optional params
+		if hdr.CmdStatus != STATUS_ESME_ROK {
+			err = newSMPPError(hdr)
+			return
+		}
+		// Update connection state
+		smpp.updateState(hdr)
+		return
+	}
+	return
+}
+
+// Max command length read, room for a full message payload and the other params
+const MAX_COMMAND_LEN = MAX_MESSAGE_PAYLOAD_LEN + 1024
+
+// Read the next PDU from the connection
+func (smpp *smpp) readPDU() (rpdu PDU, err os.Error) {
+	hdr, p, err := smpp.readRaw()
 	if err != nil {
 		return nil, err
 	}
-	// Has packet been read
-	pduRead := false	
-	// Defer reading rest of packet from buffer on error
-	defer func() {
-		if err != nil && !pduRead && hdr.CmdLength > 16 {
-			p := make([]byte, hdr.CmdLength - 16)
-			smpp.reader.Read(p)
+	return smpp.decode(hdr, p)
+}
+
+// Decode a PDU read from the connection, requests that can't be decoded are nacked
+// Responses with invalid optional params are kept as the mandatory params were decoded
+func (smpp *smpp) decode(hdr *PDUHeader, p []byte) (rpdu PDU, err os.Error) {
+	rpdu, err = decodePDU(hdr, p)
+	if err == nil {
+		return
+	}
+	_, optErr := err.(*OptionalParamError)
+	if hdr.CmdId & 0x80000000 != 0 {
+		if optErr {
+			err = nil
 		}
-	}()
-	// Check cmd and/or sequence if not 0
-	if cmd != CMD_NONE && hdr.CmdId != cmd {
-		err = os.NewError("Get Response: Invalid command")
-		return nil, err
+		return
 	}
-	// Check sequence number if not 0
-	if sequence > 0 && hdr.Sequence != sequence {
-		err = os.NewError("Get Response: Invalid sequence number")
-		return nil, err
+	// Unknown or malformed, the whole PDU was read so the stream is still in sync
+	status := SMPPCommandStatus(STATUS_ESME_RINVCMDLEN)
+	if newPDU(hdr.CmdId) == nil {
+		status = STATUS_ESME_RINVCMDID
+	} else if optErr {
+		status = STATUS_ESME_RINVOPTPARSTREAM
+	}
+	smpp.genericNack(hdr.Sequence, status)
+	return nil, err
+}
+
+// Read the next PDU header and body, the whole PDU is consumed so the stream stays in sync
+func (smpp *smpp) readRaw() (hdr *PDUHeader, p []byte, err os.Error) {
+	// Read the header
+	hdr = new(PDUHeader)
+	err = hdr.read(smpp.reader)
+	if err != nil {
+		return nil, nil, err
 	}
-	// Check for error response
-	if hdr.CmdStatus != STATUS_ESME_ROK {
-		err = os.NewError("Get Response: PDU contains an error")
+	if hdr.CmdLength < 16 || hdr.CmdLength > MAX_COMMAND_LEN {
+		// The stream can't be resynchronised, nack and drop the connection
+		smpp.genericNack(hdr.Sequence, STATUS_ESME_RINVCMDLEN)
+		smpp.close()
+		err = os.NewError("Read PDU: Invalid command length")
+		return nil, nil, err
+	}
+	// Read the body
+	p = make([]byte, hdr.CmdLength - 16)
+	_, err = io.ReadFull(smpp.reader, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// Send a request PDU with the next sequence number
+func (smpp *smpp) sendPDU(pdu PDU) (sequence uint32, err os.Error) {
+	smpp.mutex.Lock()
+	defer smpp.mutex.Unlock()
+	// Increment sequence number
+	smpp.sequence ++
+	sequence = smpp.sequence
+	pdu.GetHeader().Sequence = sequence
+	// Send PDU
+	err = smpp.write(pdu)
+	return
+}
+
+// Write a PDU to the connection, v3.3 sessions can't send optional params
+func (smpp *smpp) write(pdu PDU) (err os.Error) {
+	if smpp.version == SMPP_INTERFACE_VER_33 && len(pdu.getOptional()) > 0 {
+		err = os.NewError("Send: Optional params are not supported by interface version 3.3")
+		return
+	}
+	err = pdu.write(smpp.writer)
+	return
+}
+
+// Normalise message ids received from v3.3 SMSCs, hex ids in responses are converted to decimal to match receipts
+func (smpp *smpp) normaliseIds(rpdu PDU) {
+	if smpp.version != SMPP_INTERFACE_VER_33 {
+		return
+	}
+	switch pdu := rpdu.(type) {
+		case *PDUSubmitSMResp:
+			pdu.MessageId = NormaliseMessageId(pdu.MessageId, true)
+		case *PDUSubmitMultiResp:
+			pdu.MessageId = NormaliseMessageId(pdu.MessageId, true)
+		case *PDUQuerySMResp:
+			pdu.MessageId = NormaliseMessageId(pdu.MessageId, true)
+		case *PDUDeliverSM:
+			pdu.normaliseIds = true
+	}
+}
+
+// Get the message id to send to the SMSC, v3.3 SMSCs expect the hex id
+func (smpp *smpp) messageId(id string) string {
+	if smpp.version != SMPP_INTERFACE_VER_33 {
+		return id
+	}
+	n, err := strconv.Btoui64(id, 10)
+	if err != nil {
+		return id
+	}
+	return strings.ToUpper(strconv.Uitob64(n, 16))
+}
+
+// Send a request PDU and wait for the response
+func (smpp *smpp) request(pdu PDU, rcmd SMPPCommand) (rpdu PDU, err os.Error) {
+	// Wait on the pipeline if the reader is running
+	if smpp.isReading() {
+		res, err := smpp.sendAsync(pdu)
+		if err != nil {
+			return nil, err
+		}
+		r := <-res
+		if r.Err != nil {
+			return r.PDU, r.Err
+		}
+		if r.PDU.GetHeader().CmdId != rcmd {
+			err = os.NewError("Get Response: Invalid command")
+			return nil, err
+		}
+		return r.PDU, nil
+	}
+	// Otherwise read the response directly
+	sequence, err := smpp.sendPDU(pdu)
+	if err != nil {
 		return nil, err
 	}
-	// Set PDU as read (to disable the deferred read)
-	pduRead = true
-	// Get response PDU
+	return smpp.GetResp(rcmd, sequence)
+}
+
+// Update the connection state following a response
+func (smpp *smpp) updateState(hdr *PDUHeader) {
 	switch hdr.CmdId {
-		// Default unhandled PDU
-		default:
-			err = os.NewError("Get Response: Unknown or unhandled PDU received")
-			return nil, err
-		// Bind responses
+		// Set connection as bound
 		case CMD_BIND_RECEIVER_RESP, CMD_BIND_TRANSMITTER_RESP, CMD_BIND_TRANSCEIVER_RESP:
-			rpdu = new(PDUBindResp)
-			rpdu.setHeader(hdr)
-			err = rpdu.read(smpp.reader)
-			if err != nil {
-				return nil, err
-			}
-			// Set connection as bound
 			smpp.bound = true
-		// Unbind response
+			smpp.setState(STATE_BOUND)
+		// Set connection as unbound and disconnect
 		case CMD_UNBIND_RESP:
-			rpdu = new(PDUUnbindResp)
-			rpdu.setHeader(hdr)
-			err = rpdu.read(smpp.reader)
-			if err != nil {
-				return nil, err
-			}
-			// Set connection as unbound and disconnect
 			smpp.bound = false
+			smpp.setState(STATE_CLOSED)
 			smpp.close()
-		// SubmitSM response
-		case CMD_SUBMIT_SM_RESP:
-			rpdu = new(PDUSubmitSMResp)
-			rpdu.setHeader(hdr)
-			err = rpdu.read(smpp.reader)
-			if err != nil {
-				return nil, err
-			}
-		// SubmitMulti response
-		case CMD_SUBMIT_MULTI_RESP:
-			rpdu = new(PDUSubmitMultiResp)
-			rpdu.setHeader(hdr)
-			err = rpdu.read(smpp.reader)
-			if err != nil {
-				return nil, err
-			}
 	}
+}
+
+// Send enquire link request
+func (smpp *smpp) EnquireLink() (sequence uint32, err os.Error) {
+	// Check connected and bound
+	if !smpp.connected || !smpp.bound {
+		err = os.NewError("EnquireLink: A bound connection is required to enquire link")
+		return
+	}
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_ENQUIRE_LINK
+	hdr.CmdStatus = STATUS_ESME_ROK
+	// Create enquire link PDU
+	pdu := new(PDUEnquireLink)
+	pdu.setHeader(hdr)
+	// If async send the PDU and return the sequence number, else get the response
+	if smpp.async {
+		sequence, err = smpp.sendRequest(pdu)
+	} else {
+		_, err = smpp.request(pdu, CMD_ENQUIRE_LINK_RESP)
+	}
+	return
+}
+
+// Send a response PDU
+func (smpp *smpp) sendResp(pdu PDU) (err os.Error) {
+	smpp.mutex.Lock()
+	defer smpp.mutex.Unlock()
+	err = smpp.write(pdu)
+	return
+}
+
+// Send DeliverSM response
+func (smpp *smpp) deliverSMResp(sequence uint32, status SMPPCommandStatus) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_DELIVER_SM_RESP
+	hdr.CmdStatus = status
+	hdr.Sequence  = sequence
+	// Create DeliverSM response PDU
+	pdu := new(PDUDeliverSMResp)
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = smpp.sendResp(pdu)
+	return
+}
+
+// Send GenericNack
+func (smpp *smpp) genericNack(sequence uint32, status SMPPCommandStatus) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_GENERIC_NACK
+	hdr.CmdStatus = status
+	hdr.Sequence  = sequence
+	// Create GenericNack PDU
+	pdu := new(PDUGenericNack)
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = smpp.sendResp(pdu)
+	return
+}
+
+// Send EnquireLink response
+func (smpp *smpp) enquireLinkResp(sequence uint32) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_ENQUIRE_LINK_RESP
+	hdr.CmdStatus = STATUS_ESME_ROK
+	hdr.Sequence  = sequence
+	// Create EnquireLink response PDU
+	pdu := new(PDUEnquireLinkResp)
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = smpp.sendResp(pdu)
+	return
+}
+
+// Send Unbind response
+func (smpp *smpp) unbindResp(sequence uint32) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_UNBIND_RESP
+	hdr.CmdStatus = STATUS_ESME_ROK
+	hdr.Sequence  = sequence
+	// Create Unbind response PDU
+	pdu := new(PDUUnbindResp)
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = smpp.sendResp(pdu)
 	return
 }
 
 // Create a new Transmitter
-func NewTransmitter(host string, port int, params Params) (tx *Transmitter, err os.Error) {
-	// Merge params with defaults
-	allParams := mergeParams(params, defaultsBind)
+func NewTransmitter(host string, port int, params *BindParams) (tx *Transmitter, err os.Error) {
 	// Create new transmitter
 	tx = new(Transmitter)
 	// Connect to server
@@ -228,7 +500,7 @@ func NewTransmitter(host string, port int, params Params) (tx *Transmitter, err
 		}
 	}()
 	// Bind with server
-	err = tx.bind(CMD_BIND_TRANSMITTER, CMD_BIND_TRANSMITTER_RESP, allParams)
+	err = tx.bind(CMD_BIND_TRANSMITTER, CMD_BIND_TRANSMITTER_RESP, params)
 	if err != nil {
 		return nil, err
 	}
@@ -236,9 +508,7 @@ func NewTransmitter(host string, port int, params Params) (tx *Transmitter, err
 }
 
 // Create a new Receiver
-func NewReceiver(host string, port int, params Params) (rx *Receiver, err os.Error) {
-	// Merge params with defaults
-	allParams := mergeParams(params, defaultsBind)
+func NewReceiver(host string, port int, params *BindParams) (rx *Receiver, err os.Error) {
 	// Create new receiver
 	rx = new(Receiver)
 	// Connect to server
@@ -253,7 +523,7 @@ func NewReceiver(host string, port int, params Params) (rx *Receiver, err os.Err
 		}
 	}()
 	// Bind with server
-	err = rx.bind(CMD_BIND_RECEIVER, CMD_BIND_RECEIVER_RESP, allParams)
+	err = rx.bind(CMD_BIND_RECEIVER, CMD_BIND_RECEIVER_RESP, params)
 	if err != nil {
 		return nil, err
 	}
@@ -261,10 +531,8 @@ func NewReceiver(host string, port int, params Params) (rx *Receiver, err os.Err
 }
 
 // Create a new Transceiver
-func NewTransceiver(host string, port int, params Params) (trx *Transceiver, err os.Error) {
-	// Merge params with defaults
-	allParams := mergeParams(params, defaultsBind)
-	// Create new receiver
+func NewTransceiver(host string, port int, params *BindParams) (trx *Transceiver, err os.Error) {
+	// Create new transceiver
 	trx = new(Transceiver)
 	// Connect to server
 	err = trx.connect(host, port)
@@ -278,7 +546,12 @@ func NewTransceiver(host string, port int, params Params) (trx *Transceiver, err
 		}
 	}()
 	// Bind with server
-	err = trx.bind(CMD_BIND_TRANSCEIVER, CMD_BIND_TRANSCEIVER_RESP, allParams)
+	err = trx.bind(CMD_BIND_TRANSCEIVER, CMD_BIND_TRANSCEIVER_RESP, params)
+	if err != nil {
+		return nil, err
+	}
+	// Start reading so inbound requests are never mistaken for responses
+	err = trx.startReader()
 	if err != nil {
 		return nil, err
 	}
@@ -286,6 +559,46 @@ func NewTransceiver(host string, port int, params Params) (trx *Transceiver, err
 }
 
 // Create a new Server
-func NewServer() {
+func NewServer(host string, port int, systemId string, auth Authenticator, handler ServerHandler) (srv *Server, err os.Error) {
+	// Check handler
+	if handler == nil {
+		err = os.NewError("NewServer: A handler is required")
+		return
+	}
+	// Create new server
+	srv = new(Server)
+	srv.systemId = systemId
+	srv.auth     = auth
+	srv.handler  = handler
+	srv.sessions = make(map[*ServerSession]bool)
+	// Listen for connections
+	err = srv.listen(host, port)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Create a new Outbind listener, outbinds must match the system id and password
+func NewOutbindListener(host string, port int, systemId, password string, params *BindParams) (ol *OutbindListener, err os.Error) {
+	// Use defaults if no params
+	if params == nil {
+		params = NewBindParams()
+	}
+	// Validate params
+	err = params.Validate()
+	if err != nil {
+		return
+	}
+	// Create new outbind listener
+	ol = new(OutbindListener)
+	ol.systemId = systemId
+	ol.password = password
+	ol.params   = params
+	// Listen for connections
+	err = ol.listen(host, port)
+	if err != nil {
+		return nil, err
+	}
 	return
 }
@@ -0,0 +1,256 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"os"
+)
+
+// Default max number of unacknowledged requests
+const DEFAULT_WINDOW = 10
+
+// Response to an asynchronous request
+type Response struct {
+	Sequence	uint32
+	PDU		PDU
+	Err		os.Error
+}
+
+// Response handler for requests sent in async mode
+type ResponseHandler func(res *Response)
+
+// Set the handler for responses to requests sent in async mode, each response is passed on its own goroutine
+func (smpp *smpp) HandleResponse(handler ResponseHandler) {
+	smpp.onResponse = handler
+}
+
+// Set the max number of unacknowledged requests, must be set before the first asynchronous request
+func (smpp *smpp) Window(size int) {
+	smpp.window = size
+}
+
+// Check if the response reader is running
+func (smpp *smpp) isReading() bool {
+	smpp.mutex.Lock()
+	defer smpp.mutex.Unlock()
+	return smpp.reading
+}
+
+// Start the response reader if not already running
+func (smpp *smpp) startReader() (err os.Error) {
+	smpp.mutex.Lock()
+	defer smpp.mutex.Unlock()
+	if smpp.reading {
+		return
+	}
+	if !smpp.connected || !smpp.bound {
+		err = os.NewError("Start Reader: A bound connection is required to read responses")
+		return
+	}
+	// Setup the window and pending requests
+	if smpp.window <= 0 {
+		smpp.window = DEFAULT_WINDOW
+	}
+	smpp.inflight = make(chan bool, smpp.window)
+	smpp.pending  = make(map[uint32]chan *Response)
+	smpp.done     = make(chan bool)
+	smpp.readErr  = nil
+	smpp.reading  = true
+	go smpp.readLoop()
+	// Start keepalive if enabled
+	if smpp.interval > 0 {
+		smpp.startKeepalive()
+	}
+	// Expire incomplete concatenated messages
+	if smpp.reassemble {
+		smpp.startReassembly()
+	}
+	return
+}
+
+// Send a request PDU asynchronously, blocks while the window is full
+func (smpp *smpp) sendAsync(pdu PDU) (res chan *Response, err os.Error) {
+	// Start reading responses
+	err = smpp.startReader()
+	if err != nil {
+		return
+	}
+	// Wait for a free slot in the window
+	smpp.inflight <- true
+	return smpp.sendInflight(pdu)
+}
+
+// Send a request PDU once a slot in the window is held, the slot is freed when the response is received
+func (smpp *smpp) sendInflight(pdu PDU) (res chan *Response, err os.Error) {
+	smpp.mutex.Lock()
+	defer smpp.mutex.Unlock()
+	// Reader may have stopped while waiting
+	if !smpp.reading {
+		<-smpp.inflight
+		err = os.NewError("Send: Connection closed")
+		return nil, err
+	}
+	// Increment sequence number
+	smpp.sequence ++
+	pdu.GetHeader().Sequence = smpp.sequence
+	// Register the response channel first as the response may arrive before write returns
+	res = make(chan *Response, 1)
+	smpp.pending[smpp.sequence] = res
+	// Send PDU
+	err = smpp.write(pdu)
+	if err != nil {
+		smpp.pending[smpp.sequence] = nil, false
+		<-smpp.inflight
+		return nil, err
+	}
+	return
+}
+
+// Send a request PDU in async mode, the response is passed to the response handler once received
+func (smpp *smpp) sendRequest(pdu PDU) (sequence uint32, err os.Error) {
+	res, err := smpp.sendAsync(pdu)
+	if err != nil {
+		return
+	}
+	sequence = pdu.GetHeader().Sequence
+	handler := smpp.onResponse
+	go func() {
+		r := <-res
+		if handler != nil {
+			handler(r)
+		}
+	}()
+	return
+}
+
+// Read PDUs and pass responses to the pending requests by sequence number
+func (smpp *smpp) readLoop() {
+	var err os.Error
+	for {
+		var hdr *PDUHeader
+		var p []byte
+		hdr, p, err = smpp.readRaw()
+		if err != nil {
+			break
+		}
+		// Only responses are matched to requests, inbound requests have their own sequence
+		if hdr.CmdId & 0x80000000 == 0 {
+			rpdu, rerr := smpp.decode(hdr, p)
+			if rerr != nil {
+				continue
+			}
+			smpp.normaliseIds(rpdu)
+			if smpp.onRequest != nil {
+				smpp.onRequest(rpdu)
+			} else {
+				smpp.handleRequest(rpdu)
+			}
+			continue
+		}
+		// Decode response, a generic nack fails the request with the same sequence
+		rpdu, rerr := smpp.decode(hdr, p)
+		if rpdu != nil {
+			smpp.normaliseIds(rpdu)
+		}
+		if rerr == nil && (hdr.CmdStatus != STATUS_ESME_ROK || hdr.CmdId == CMD_GENERIC_NACK) {
+			rerr = newSMPPError(hdr)
+		}
+		if rerr == nil {
+			smpp.updateState(hdr)
+		}
+		// Pass to pending request
+		smpp.mutex.Lock()
+		res, ok := smpp.pending[hdr.Sequence]
+		if ok {
+			smpp.pending[hdr.Sequence] = nil, false
+		}
+		smpp.mutex.Unlock()
+		if ok {
+			res <- &Response{hdr.Sequence, rpdu, rerr}
+			<-smpp.inflight
+		}
+	}
+	// Connection lost or closed, fail all pending requests
+	smpp.mutex.Lock()
+	smpp.reading = false
+	for seq, res := range smpp.pending {
+		res <- &Response{seq, nil, err}
+		<-smpp.inflight
+	}
+	smpp.pending = make(map[uint32]chan *Response)
+	// Closing after unbind is not an error
+	if smpp.bound {
+		smpp.readErr = err
+		// Connection was lost, flags are no longer valid
+		smpp.bound = false
+		if smpp.connected {
+			smpp.close()
+		}
+	}
+	smpp.mutex.Unlock()
+	// Supervisor handles state after the reader stops
+	if !smpp.supervised {
+		smpp.setState(STATE_CLOSED)
+	}
+	close(smpp.done)
+}
+
+// Handle a request PDU received from the SMSC
+func (smpp *smpp) handleRequest(rpdu PDU) {
+	hdr := rpdu.GetHeader()
+	switch pdu := rpdu.(type) {
+		// Reply to enquire link
+		case *PDUEnquireLink:
+			smpp.enquireLinkResp(hdr.Sequence)
+		// Reply to unbind and disconnect
+		case *PDUUnbind:
+			smpp.handleUnbind(hdr.Sequence)
+		// Pass messages to the handler and respond with the returned status
+		case *PDUDeliverSM:
+			// Parts of concatenated messages are acknowledged until the message is complete
+			if pdu = smpp.reassembleSM(pdu); pdu == nil {
+				smpp.deliverSMResp(hdr.Sequence, STATUS_ESME_ROK)
+				return
+			}
+			// Handlers may send requests of their own so they can't block the reader
+			go smpp.handleDeliverSM(hdr.Sequence, pdu)
+		// Pass data to the handler and respond with the returned status
+		case *PDUDataSM:
+			go smpp.handleDataSM(hdr.Sequence, pdu)
+		// Not supported by this session
+		default:
+			smpp.genericNack(hdr.Sequence, STATUS_ESME_RINVCMDID)
+	}
+}
+
+// Reply to an unbind from the peer and disconnect, a supervised session reconnects
+func (smpp *smpp) handleUnbind(sequence uint32) {
+	smpp.unbindResp(sequence)
+	smpp.mutex.Lock()
+	smpp.bound = false
+	smpp.mutex.Unlock()
+	if !smpp.supervised {
+		smpp.setState(STATE_CLOSED)
+	}
+	smpp.close()
+}
+
+// Pass a message to the deliver SM handler and send the response
+func (smpp *smpp) handleDeliverSM(sequence uint32, pdu *PDUDeliverSM) {
+	status := SMPPCommandStatus(STATUS_ESME_RX_T_APPN)
+	if smpp.deliverSM != nil {
+		status = smpp.deliverSM(pdu)
+	}
+	smpp.deliverSMResp(sequence, status)
+}
+
+// Pass data to the data SM handler and send the response
+func (smpp *smpp) handleDataSM(sequence uint32, pdu *PDUDataSM) {
+	msgId, optional, status := "", OptParams(nil), SMPPCommandStatus(STATUS_ESME_RX_T_APPN)
+	if smpp.dataSM != nil {
+		msgId, optional, status = smpp.dataSM(pdu)
+	}
+	smpp.dataSMResp(sequence, msgId, optional, status)
+}
@@ -0,0 +1,203 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"os"
+	"io"
+	"net"
+	"bufio"
+	"testing"
+)
+
+// Test peer, the other end of an in-memory connection
+type testPeer struct {
+	conn	net.Conn
+	reader	*bufio.Reader
+	writer	*bufio.Writer
+}
+
+// Create a bound session connected to a test peer with the reader started
+func testSession(t *testing.T, window int) (sess *smpp, peer *testPeer) {
+	c1, c2 := net.Pipe()
+	sess = new(smpp)
+	sess.conn      = c1
+	sess.reader    = bufio.NewReader(c1)
+	sess.writer    = bufio.NewWriter(c1)
+	sess.connected = true
+	sess.bound     = true
+	sess.Window(window)
+	err := sess.startReader()
+	if err != nil {
+		t.Fatalf("startReader: %s", err)
+	}
+	peer = &testPeer{c2, bufio.NewReader(c2), bufio.NewWriter(c2)}
+	return
+}
+
+// Read the next PDU header from the session, the body is discarded
+func (peer *testPeer) read() (hdr *PDUHeader, err os.Error) {
+	hdr = new(PDUHeader)
+	err = hdr.read(peer.reader)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.ReadFull(peer.reader, make([]byte, hdr.CmdLength - 16))
+	return
+}
+
+// Close the peer connection and wait for the session reader to stop
+func (peer *testPeer) close(sess *smpp) {
+	peer.conn.Close()
+	<-sess.done
+}
+
+// Send a response without a body to the session
+func (peer *testPeer) respond(cmd SMPPCommand, status SMPPCommandStatus, sequence uint32) os.Error {
+	hdr := &PDUHeader{16, cmd, status, sequence}
+	return hdr.write(peer.writer)
+}
+
+// Create a submit SM request with all fields empty
+func testSubmitSM() PDU {
+	pdu := new(PDUSubmitSM)
+	pdu.setHeader(&PDUHeader{34, CMD_SUBMIT_SM, STATUS_ESME_ROK, 0})
+	return pdu
+}
+
+// Responses are matched to requests by sequence whatever the order they arrive in
+func TestAsyncOutOfOrder(t *testing.T) {
+	sess, peer := testSession(t, 3)
+	defer peer.close(sess)
+	// Peer reads all requests then responds in reverse order
+	go func() {
+		seqs := make([]uint32, 3)
+		for i := range seqs {
+			hdr, err := peer.read()
+			if err != nil {
+				return
+			}
+			seqs[i] = hdr.Sequence
+		}
+		for i := len(seqs) - 1; i >= 0; i -- {
+			peer.respond(CMD_SUBMIT_SM_RESP, STATUS_ESME_ROK, seqs[i])
+		}
+	}()
+	reqs := make([]PDU, 3)
+	res := make([]chan *Response, 3)
+	for i := range reqs {
+		reqs[i] = testSubmitSM()
+		var err os.Error
+		res[i], err = sess.sendAsync(reqs[i])
+		if err != nil {
+			t.Fatalf("sendAsync: %s", err)
+		}
+	}
+	for i, req := range reqs {
+		r := <-res[i]
+		seq := req.GetHeader().Sequence
+		if r.Err != nil || r.Sequence != seq {
+			t.Errorf("request %d: response sequence %d, %v, want %d", i, r.Sequence, r.Err, seq)
+			continue
+		}
+		if _, ok := r.PDU.(*PDUSubmitSMResp); !ok || r.PDU.GetHeader().Sequence != seq {
+			t.Errorf("request %d: response %#v, want submit_sm_resp sequence %d", i, r.PDU, seq)
+		}
+	}
+}
+
+// Generic nack fails the request with the same sequence
+func TestAsyncGenericNack(t *testing.T) {
+	sess, peer := testSession(t, 1)
+	defer peer.close(sess)
+	go func() {
+		hdr, err := peer.read()
+		if err != nil {
+			return
+		}
+		peer.respond(CMD_GENERIC_NACK, STATUS_ESME_RTHROTTLED, hdr.Sequence)
+	}()
+	req := testSubmitSM()
+	res, err := sess.sendAsync(req)
+	if err != nil {
+		t.Fatalf("sendAsync: %s", err)
+	}
+	r := <-res
+	serr, ok := r.Err.(*SMPPError)
+	if !ok || serr.Status != STATUS_ESME_RTHROTTLED || serr.CmdId != CMD_GENERIC_NACK || r.Sequence != req.GetHeader().Sequence {
+		t.Errorf("response = sequence %d, %v, want generic_nack ESME_RTHROTTLED sequence %d", r.Sequence, r.Err, req.GetHeader().Sequence)
+	}
+	if !serr.Temporary() {
+		t.Errorf("ESME_RTHROTTLED is not temporary")
+	}
+}
+
+// Responses to requests sent in async mode are passed to the response handler
+func TestAsyncResponseHandler(t *testing.T) {
+	sess, peer := testSession(t, 1)
+	defer peer.close(sess)
+	go func() {
+		hdr, err := peer.read()
+		if err != nil {
+			return
+		}
+		peer.respond(CMD_SUBMIT_SM_RESP, STATUS_ESME_ROK, hdr.Sequence)
+	}()
+	handled := make(chan *Response, 1)
+	sess.HandleResponse(func(res *Response) {
+		handled <- res
+	})
+	seq, err := sess.sendRequest(testSubmitSM())
+	if err != nil {
+		t.Fatalf("sendRequest: %s", err)
+	}
+	r := <-handled
+	if r.Err != nil || r.Sequence != seq {
+		t.Errorf("handled response sequence %d, %v, want %d", r.Sequence, r.Err, seq)
+	}
+}
+
+// Pending requests fail when the connection is lost, senders waiting on a full window are released
+func TestAsyncConnectionLost(t *testing.T) {
+	sess, peer := testSession(t, 2)
+	read := make(chan bool)
+	go func() {
+		for i := 0; i < 2; i ++ {
+			if _, err := peer.read(); err != nil {
+				return
+			}
+		}
+		read <- true
+	}()
+	res := make([]chan *Response, 2)
+	for i := range res {
+		var err os.Error
+		res[i], err = sess.sendAsync(testSubmitSM())
+		if err != nil {
+			t.Fatalf("sendAsync: %s", err)
+		}
+	}
+	<-read
+	// Window is full, the next request waits for a slot
+	blocked := make(chan os.Error)
+	go func() {
+		_, err := sess.sendAsync(testSubmitSM())
+		blocked <- err
+	}()
+	peer.conn.Close()
+	for i := range res {
+		r := <-res[i]
+		if r.Err == nil || r.PDU != nil {
+			t.Errorf("request %d: response %#v, %v, want error", i, r.PDU, r.Err)
+		}
+	}
+	if err := <-blocked; err == nil {
+		t.Errorf("sendAsync on a lost connection: expected error")
+	}
+	<-sess.done
+	if sess.readErr == nil || sess.bound || sess.connected {
+		t.Errorf("after connection lost: readErr %v, bound %v, connected %v, want error, false, false", sess.readErr, sess.bound, sess.connected)
+	}
+}
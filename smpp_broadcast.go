@@ -0,0 +1,195 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"os"
+)
+
+// Broadcast SM (SMPP v5.0), the message is sent as the message payload
+func (tx *Transmitter) BroadcastSM(msg string, params *BroadcastSMParams, optional ...OptParams) (sequence uint32, msgId string, err os.Error) {
+	// Check connected and bound
+	if !tx.connected || !tx.bound {
+		err = os.NewError("BroadcastSM: A bound connection is required to broadcast a message")
+		return
+	}
+	if tx.InterfaceVersion() < SMPP_INTERFACE_VER_50 {
+		err = os.NewError("BroadcastSM: Requires interface version 5.0")
+		return
+	}
+	// Use defaults if no params
+	if params == nil {
+		params = NewBroadcastSMParams()
+	}
+	// Validate params
+	err = params.Validate()
+	if err != nil {
+		return
+	}
+	// Encode message for the data coding
+	coding := tx.messageCoding(msg, params.DataCoding)
+	payload, err := encodeMessage(msg, coding)
+	if err != nil {
+		return
+	}
+	if len(payload) > MAX_MESSAGE_PAYLOAD_LEN {
+		err = &ParamError{"MessagePayload", "exceeds 65535 octets"}
+		return
+	}
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_BROADCAST_SM
+	hdr.CmdStatus = STATUS_ESME_ROK
+	// Create new PDU
+	pdu := new(PDUBroadcastSM)
+	// Populate params
+	pdu.ServiceType    = params.ServiceType
+	pdu.SourceAddrTon  = params.SourceAddrTon
+	pdu.SourceAddrNpi  = params.SourceAddrNpi
+	pdu.SourceAddr     = params.SourceAddr
+	pdu.PriorityFlag   = params.PriorityFlag
+	pdu.SchedDelTime   = params.SchedDelTime
+	pdu.ValidityPeriod = params.ValidityPeriod
+	pdu.ReplaceFlag    = params.ReplaceFlag
+	pdu.DataCoding     = coding
+	pdu.SmDefaultMsgId = params.SmDefaultMsgId
+	// Add the required broadcast params and message payload to optional params
+	pdu.Optional = make(OptParams)
+	if len(optional) > 0 {
+		for tag, val := range optional[0] {
+			pdu.Optional[tag] = val
+		}
+	}
+	pdu.Optional[TAG_BROADCAST_AREA_IDENTIFIER]    = params.AreaIdentifier
+	pdu.Optional[TAG_BROADCAST_CONTENT_TYPE]       = params.ContentType
+	pdu.Optional[TAG_BROADCAST_REP_NUM]            = params.RepNum
+	pdu.Optional[TAG_BROADCAST_FREQUENCY_INTERVAL] = params.FrequencyInterval
+	if len(payload) > 0 {
+		pdu.Optional[TAG_MESSAGE_PAYLOAD] = []byte(payload)
+	}
+	pdu.setHeader(hdr)
+	// If async send the PDU and return the sequence number, else get the response
+	if tx.async {
+		sequence, err = tx.sendRequest(pdu)
+		return
+	}
+	rpdu, err := tx.request(pdu, CMD_BROADCAST_SM_RESP)
+	if err != nil {
+		return
+	}
+	msgId = rpdu.(*PDUBroadcastSMResp).MessageId
+	return
+}
+
+// Query Broadcast SM (SMPP v5.0), the message state and broadcast areas are in the response optional params
+func (tx *Transmitter) QueryBroadcastSM(msgId string, params *QueryBroadcastSMParams, optional ...OptParams) (sequence uint32, resp *PDUQueryBroadcastSMResp, err os.Error) {
+	// Check connected and bound
+	if !tx.connected || !tx.bound {
+		err = os.NewError("QueryBroadcastSM: A bound connection is required to query a broadcast")
+		return
+	}
+	if tx.InterfaceVersion() < SMPP_INTERFACE_VER_50 {
+		err = os.NewError("QueryBroadcastSM: Requires interface version 5.0")
+		return
+	}
+	// Check message id
+	if msgId == "" {
+		err = os.NewError("QueryBroadcastSM: A message id is required and should not be null")
+		return
+	}
+	// Use defaults if no params
+	if params == nil {
+		params = NewQueryBroadcastSMParams()
+	}
+	// Validate params
+	err = params.Validate()
+	if err != nil {
+		return
+	}
+	if err = checkCString("MessageId", msgId, MAX_MESSAGE_ID_LEN); err != nil {
+		return
+	}
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_QUERY_BROADCAST_SM
+	hdr.CmdStatus = STATUS_ESME_ROK
+	// Create new PDU
+	pdu := new(PDUQueryBroadcastSM)
+	// Populate params
+	pdu.MessageId     = msgId
+	pdu.SourceAddrTon = params.SourceAddrTon
+	pdu.SourceAddrNpi = params.SourceAddrNpi
+	pdu.SourceAddr    = params.SourceAddr
+	// Optional params
+	if len(optional) > 0 {
+		pdu.Optional = optional[0]
+	}
+	pdu.setHeader(hdr)
+	// If async send the PDU and return the sequence number, else get the response
+	if tx.async {
+		sequence, err = tx.sendRequest(pdu)
+		return
+	}
+	rpdu, err := tx.request(pdu, CMD_QUERY_BROADCAST_SM_RESP)
+	if err != nil {
+		return
+	}
+	resp = rpdu.(*PDUQueryBroadcastSMResp)
+	return
+}
+
+// Cancel Broadcast SM (SMPP v5.0), by message id or by service type if the message id is null
+func (tx *Transmitter) CancelBroadcastSM(msgId string, params *CancelBroadcastSMParams, optional ...OptParams) (sequence uint32, err os.Error) {
+	// Check connected and bound
+	if !tx.connected || !tx.bound {
+		err = os.NewError("CancelBroadcastSM: A bound connection is required to cancel a broadcast")
+		return
+	}
+	if tx.InterfaceVersion() < SMPP_INTERFACE_VER_50 {
+		err = os.NewError("CancelBroadcastSM: Requires interface version 5.0")
+		return
+	}
+	// Use defaults if no params
+	if params == nil {
+		params = NewCancelBroadcastSMParams()
+	}
+	// Check message id or service type
+	if msgId == "" && params.ServiceType == "" {
+		err = os.NewError("CancelBroadcastSM: A message id or service type is required")
+		return
+	}
+	// Validate params
+	err = params.Validate()
+	if err != nil {
+		return
+	}
+	if err = checkCString("MessageId", msgId, MAX_MESSAGE_ID_LEN); err != nil {
+		return
+	}
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_CANCEL_BROADCAST_SM
+	hdr.CmdStatus = STATUS_ESME_ROK
+	// Create new PDU
+	pdu := new(PDUCancelBroadcastSM)
+	// Populate params
+	pdu.ServiceType   = params.ServiceType
+	pdu.MessageId     = msgId
+	pdu.SourceAddrTon = params.SourceAddrTon
+	pdu.SourceAddrNpi = params.SourceAddrNpi
+	pdu.SourceAddr    = params.SourceAddr
+	// Optional params
+	if len(optional) > 0 {
+		pdu.Optional = optional[0]
+	}
+	pdu.setHeader(hdr)
+	// If async send the PDU and return the sequence number, else get the response
+	if tx.async {
+		sequence, err = tx.sendRequest(pdu)
+		return
+	}
+	_, err = tx.request(pdu, CMD_CANCEL_BROADCAST_SM_RESP)
+	return
+}
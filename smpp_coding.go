@@ -0,0 +1,174 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"os"
+	"utf16"
+)
+
+// Set automatic data coding, the message text is sent as GSM 03.38 if possible,
+// otherwise as Latin-1 (if latin1 is enabled and possible) or UCS-2
+func (smpp *smpp) AutoCoding(auto, latin1 bool) {
+	smpp.autoCoding = auto
+	smpp.autoLatin1 = latin1
+}
+
+// Get the data coding for a message, automatic if enabled otherwise the given coding
+func (smpp *smpp) messageCoding(msg string, coding SMPPDataCoding) SMPPDataCoding {
+	if !smpp.autoCoding {
+		return coding
+	}
+	return SelectCoding(msg, smpp.autoLatin1)
+}
+
+// Select the most compact data coding able to represent the text
+func SelectCoding(text string, latin1 bool) SMPPDataCoding {
+	if _, ok := GSMLength(text); ok {
+		return CODING_DEFAULT
+	}
+	if latin1 && isLatin1(text) {
+		return CODING_LATIN1
+	}
+	return CODING_UCS2
+}
+
+// Encode message text for the data coding
+func encodeMessage(msg string, coding SMPPDataCoding) (sm string, err os.Error) {
+	var p []byte
+	switch coding {
+		// Other codings are sent as is
+		default:
+			return msg, nil
+		// GSM 03.38 default alphabet
+		case CODING_DEFAULT:
+			p, err = EncodeGSM(msg)
+		// ISO-8859-1
+		case CODING_LATIN1:
+			p, err = EncodeLatin1(msg)
+		// UCS-2 (UTF-16BE)
+		case CODING_UCS2:
+			p = EncodeUCS2(msg)
+	}
+	if err != nil {
+		return "", err
+	}
+	sm = string(p)
+	return
+}
+
+// Decode a short message to text for the data coding
+func decodeMessage(sm string, coding SMPPDataCoding) (msg string, err os.Error) {
+	switch coding {
+		// Other codings are returned as is
+		default:
+			msg = sm
+		// GSM 03.38 default alphabet
+		case CODING_DEFAULT:
+			msg, err = DecodeGSM([]byte(sm))
+		// ISO-8859-1
+		case CODING_LATIN1:
+			msg = DecodeLatin1([]byte(sm))
+		// UCS-2 (UTF-16BE)
+		case CODING_UCS2:
+			msg, err = DecodeUCS2([]byte(sm))
+	}
+	return
+}
+
+// Check all characters are in ISO-8859-1
+func isLatin1(text string) bool {
+	for _, rune := range text {
+		if rune > 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode text as ISO-8859-1
+func EncodeLatin1(text string) (p []byte, err os.Error) {
+	p = make([]byte, 0, len(text))
+	for _, rune := range text {
+		if rune > 0xff {
+			err = os.NewError("Encode Latin-1: Character " + string(rune) + " not in ISO-8859-1")
+			return nil, err
+		}
+		p = append(p, byte(rune))
+	}
+	return
+}
+
+// Decode ISO-8859-1 to text
+func DecodeLatin1(p []byte) (text string) {
+	runes := make([]int, len(p))
+	for i, c := range p {
+		runes[i] = int(c)
+	}
+	text = string(runes)
+	return
+}
+
+// Encode text as UCS-2 (UTF-16BE, characters outside the BMP are encoded as surrogate pairs)
+func EncodeUCS2(text string) (p []byte) {
+	units := utf16.Encode([]int(text))
+	p = make([]byte, len(units) * 2)
+	for i, unit := range units {
+		p[i * 2]     = byte(unit >> 8)
+		p[i * 2 + 1] = byte(unit)
+	}
+	return
+}
+
+// Decode UCS-2 (UTF-16BE) to text
+func DecodeUCS2(p []byte) (text string, err os.Error) {
+	if len(p) % 2 != 0 {
+		err = os.NewError("Decode UCS-2: Odd number of octets")
+		return
+	}
+	units := make([]uint16, len(p) / 2)
+	for i := range units {
+		units[i] = uint16(p[i * 2]) << 8 | uint16(p[i * 2 + 1])
+	}
+	text = string(utf16.Decode(units))
+	return
+}
+
+// Remove the UDH from a short message
+func stripUDH(sm string) string {
+	if len(sm) == 0 || int(sm[0]) + 1 > len(sm) {
+		return ""
+	}
+	return sm[int(sm[0]) + 1:]
+}
+
+// Get the SubmitSM message text (excluding any UDH)
+func (pdu *PDUSubmitSM) Text() (string, os.Error) {
+	if pdu.EsmClass & ESME_GSM_UDHI != 0 {
+		return decodeMessage(stripUDH(pdu.ShortMessage), pdu.DataCoding)
+	}
+	return decodeMessage(pdu.ShortMessage, pdu.DataCoding)
+}
+
+// Get the SubmitMulti message text (excluding any UDH)
+func (pdu *PDUSubmitMulti) Text() (string, os.Error) {
+	if pdu.EsmClass & ESME_GSM_UDHI != 0 {
+		return decodeMessage(stripUDH(pdu.ShortMessage), pdu.DataCoding)
+	}
+	return decodeMessage(pdu.ShortMessage, pdu.DataCoding)
+}
+
+// Get the DeliverSM message text (excluding any UDH), the message payload is used if there is no short message
+func (pdu *PDUDeliverSM) Text() (string, os.Error) {
+	sm := pdu.ShortMessage
+	if sm == "" {
+		payload, _ := pdu.Optional[TAG_MESSAGE_PAYLOAD].([]byte)
+		sm = string(payload)
+	}
+	if pdu.EsmClass & SMSC_GSM_UDHI != 0 {
+		return decodeMessage(stripUDH(sm), pdu.DataCoding)
+	}
+	return decodeMessage(sm, pdu.DataCoding)
+}
@@ -6,6 +6,24 @@ package smpp
 
 const (
 	SMPP_INTERFACE_VER	= 0x34
+	SMPP_INTERFACE_VER_33	= 0x33
+	SMPP_INTERFACE_VER_34	= 0x34
+	SMPP_INTERFACE_VER_50	= 0x50
+)
+
+// UDH information element identifiers
+const (
+	UDH_IEI_CONCAT_8	= 0x00
+	UDH_IEI_CONCAT_16	= 0x08
+)
+
+type SMPPState uint8
+
+const (
+	STATE_CLOSED		= 0x00
+	STATE_CONNECTING	= 0x01
+	STATE_BOUND		= 0x02
+	STATE_UNBINDING		= 0x03
 )
 
 type SMPPCommand uint32
@@ -38,6 +56,12 @@ const (
 	CMD_SUBMIT_MULTI_RESP		= 0x80000021
 	CMD_DATA_SM			= 0x00000103
 	CMD_DATA_SM_RESP		= 0x80000103
+	CMD_BROADCAST_SM		= 0x00000111
+	CMD_BROADCAST_SM_RESP		= 0x80000111
+	CMD_QUERY_BROADCAST_SM		= 0x00000112
+	CMD_QUERY_BROADCAST_SM_RESP	= 0x80000112
+	CMD_CANCEL_BROADCAST_SM		= 0x00000113
+	CMD_CANCEL_BROADCAST_SM_RESP	= 0x80000113
 )
 
 type SMPPCommandStatus uint32
@@ -91,6 +115,26 @@ const (
 	STATUS_ESME_RINVOPTPARAMVAL	= 0x000000c4	// Invalid Optional Parameter Value
 	STATUS_ESME_RDELIVERYFAILURE	= 0x000000fe	// Delivery Failure (used for data_sm_resp)
 	STATUS_ESME_RUNKNOWNERR		= 0x000000ff	// Unknown Error
+	// SMPP v5.0
+	STATUS_ESME_RSERTYPUNAUTH	= 0x00000100	// ESME Not authorised to use specified service_type
+	STATUS_ESME_RPROHIBITED		= 0x00000101	// ESME Prohibited from using specified operation
+	STATUS_ESME_RSERTYPUNAVAIL	= 0x00000102	// Specified service_type is unavailable
+	STATUS_ESME_RSERTYPDENIED	= 0x00000103	// Specified service_type is denied
+	STATUS_ESME_RINVDCS		= 0x00000104	// Invalid Data Coding Scheme
+	STATUS_ESME_RINVSRCADDRSUBUNIT	= 0x00000105	// Source Address Sub unit is Invalid
+	STATUS_ESME_RINVDSTADDRSUBUNIT	= 0x00000106	// Destination Address Sub unit is Invalid
+	STATUS_ESME_RINVBCASTFREQINT	= 0x00000107	// Broadcast Frequency Interval is invalid
+	STATUS_ESME_RINVBCASTALIAS_NAME	= 0x00000108	// Broadcast Alias Name is invalid
+	STATUS_ESME_RINVBCASTAREAFMT	= 0x00000109	// Broadcast Area Format is invalid
+	STATUS_ESME_RINVNUMBCAST_AREAS	= 0x0000010a	// Number of Broadcast Areas is invalid
+	STATUS_ESME_RINVBCASTCNTTYPE	= 0x0000010b	// Broadcast Content Type is invalid
+	STATUS_ESME_RINVBCASTMSGCLASS	= 0x0000010c	// Broadcast Message Class is invalid
+	STATUS_ESME_RBCASTFAIL		= 0x0000010d	// broadcast_sm operation failed
+	STATUS_ESME_RBCASTQUERYFAIL	= 0x0000010e	// query_broadcast_sm operation failed
+	STATUS_ESME_RBCASTCANCELFAIL	= 0x0000010f	// cancel_broadcast_sm operation failed
+	STATUS_ESME_RINVBCAST_REP	= 0x00000110	// Number of Repeated Broadcasts is invalid
+	STATUS_ESME_RINVBCASTSRVGRP	= 0x00000111	// Broadcast Service Group is invalid
+	STATUS_ESME_RINVBCASTCHANIND	= 0x00000112	// Broadcast Channel Indicator is invalid
 )
 
 type SMPPTypeOfNumber uint8
@@ -187,6 +231,37 @@ const (
 	CODING_KS_C_5601	= 0x0e
 )
 
+type SMPPMessageState uint8
+
+const (
+	MSG_STATE_ENROUTE	= 0x01
+	MSG_STATE_DELIVERED	= 0x02
+	MSG_STATE_EXPIRED	= 0x03
+	MSG_STATE_DELETED	= 0x04
+	MSG_STATE_UNDELIVERABLE	= 0x05
+	MSG_STATE_ACCEPTED	= 0x06
+	MSG_STATE_UNKNOWN	= 0x07
+	MSG_STATE_REJECTED	= 0x08
+)
+
+type SMPPSegmentation uint8
+
+const (
+	SEGMENT_UDH8		= 0x00	// UDH concatenation with 8-bit reference
+	SEGMENT_UDH16		= 0x01	// UDH concatenation with 16-bit reference
+	SEGMENT_SAR		= 0x02	// SAR optional params
+)
+
+type SMPPParamType uint8
+
+const (
+	TLV_INT1		= 0x00	// 1 octet integer
+	TLV_INT2		= 0x01	// 2 octet integer
+	TLV_INT4		= 0x02	// 4 octet integer
+	TLV_COCTET		= 0x03	// C-octet string
+	TLV_OCTET		= 0x04	// Octet string
+)
+
 type SMPPEsmClassSMSC uint8
 
 const (
@@ -242,7 +317,27 @@ const (
 	TAG_DELIVERY_FAILURE_REASON	= 0x0425
 	TAG_MORE_MESSAGES_TO_SEND	= 0x0426
 	TAG_MESSAGE_STATE		= 0x0427
+	TAG_CONGESTION_STATE		= 0x0428
 	TAG_USSD_SERVICE_OP		= 0x0501
+	TAG_BROADCAST_CHANNEL_INDICATOR	= 0x0600
+	TAG_BROADCAST_CONTENT_TYPE	= 0x0601
+	TAG_BROADCAST_CONTENT_TYPE_INFO	= 0x0602
+	TAG_BROADCAST_MESSAGE_CLASS	= 0x0603
+	TAG_BROADCAST_REP_NUM		= 0x0604
+	TAG_BROADCAST_FREQUENCY_INTERVAL	= 0x0605
+	TAG_BROADCAST_AREA_IDENTIFIER	= 0x0606
+	TAG_BROADCAST_ERROR_STATUS	= 0x0607
+	TAG_BROADCAST_AREA_SUCCESS	= 0x0608
+	TAG_BROADCAST_END_TIME		= 0x0609
+	TAG_BROADCAST_SERVICE_GROUP	= 0x060a
+	TAG_BILLING_IDENTIFICATION	= 0x060b
+	TAG_SOURCE_NETWORK_ID		= 0x060d
+	TAG_DEST_NETWORK_ID		= 0x060e
+	TAG_SOURCE_NODE_ID		= 0x060f
+	TAG_DEST_NODE_ID		= 0x0610
+	TAG_DEST_ADDR_NP_RESOLUTION	= 0x0611
+	TAG_DEST_ADDR_NP_INFORMATION	= 0x0612
+	TAG_DEST_ADDR_NP_COUNTRY	= 0x0613
 	TAG_DISPLAY_TIME		= 0x1201
 	TAG_SMS_SIGNAL			= 0x1203
 	TAG_MS_VALIDITY			= 0x1204
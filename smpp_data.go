@@ -0,0 +1,116 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"os"
+)
+
+// Max message payload length
+const MAX_MESSAGE_PAYLOAD_LEN = 65535
+
+// DataSM handler, returns the message id, optional params and command status sent in the data_sm_resp
+// The optional params are sent with error responses as they may contain the delivery failure reason
+type DataSMHandler func(pdu *PDUDataSM) (msgId string, optional OptParams, status SMPPCommandStatus)
+
+// Set the handler for inbound DataSM, must be set before receiving messages
+func (smpp *smpp) HandleDataSM(handler DataSMHandler) {
+	smpp.dataSM = handler
+}
+
+// Data SM, the message is sent in the message payload optional param if not null
+// The response is returned on error if received as it may contain the delivery failure reason, network error code and additional status info text
+func (smpp *smpp) DataSM(dest, msg string, params *DataSMParams, optional ...OptParams) (sequence uint32, resp *PDUDataSMResp, err os.Error) {
+	// Check connected and bound
+	if !smpp.connected || !smpp.bound {
+		err = os.NewError("DataSM: A bound connection is required to send data")
+		return
+	}
+	// Check destination number
+	if dest == "" {
+		err = os.NewError("DataSM: A destination number is required and should not be null")
+		return
+	}
+	// Use defaults if no params
+	if params == nil {
+		params = NewDataSMParams()
+	}
+	// Validate params
+	err = params.Validate()
+	if err != nil {
+		return
+	}
+	if err = checkCString("DestAddr", dest, MAX_ADDR_LEN); err != nil {
+		return
+	}
+	// Encode message for the data coding
+	coding := smpp.messageCoding(msg, params.DataCoding)
+	payload, err := encodeMessage(msg, coding)
+	if err != nil {
+		return
+	}
+	if len(payload) > MAX_MESSAGE_PAYLOAD_LEN {
+		err = &ParamError{"MessagePayload", "exceeds 65535 octets"}
+		return
+	}
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_DATA_SM
+	hdr.CmdStatus = STATUS_ESME_ROK
+	// Create new PDU
+	pdu := new(PDUDataSM)
+	// Populate params
+	pdu.ServiceType   = params.ServiceType
+	pdu.SourceAddrTon = params.SourceAddrTon
+	pdu.SourceAddrNpi = params.SourceAddrNpi
+	pdu.SourceAddr    = params.SourceAddr
+	pdu.DestAddrTon   = params.DestAddrTon
+	pdu.DestAddrNpi   = params.DestAddrNpi
+	pdu.DestAddr      = dest
+	pdu.EsmClass      = params.EsmClass
+	pdu.RegDelivery   = params.RegDelivery
+	pdu.DataCoding    = coding
+	// Add message payload to optional params
+	pdu.Optional = make(OptParams)
+	if len(optional) > 0 {
+		for tag, val := range optional[0] {
+			pdu.Optional[tag] = val
+		}
+	}
+	if len(payload) > 0 {
+		pdu.Optional[TAG_MESSAGE_PAYLOAD] = []byte(payload)
+	}
+	pdu.setHeader(hdr)
+	// If async send the PDU and return the sequence number, else get the response
+	if smpp.async {
+		sequence, err = smpp.sendRequest(pdu)
+		return
+	}
+	rpdu, err := smpp.request(pdu, CMD_DATA_SM_RESP)
+	if rpdu != nil {
+		resp, _ = rpdu.(*PDUDataSMResp)
+	}
+	return
+}
+
+// Send DataSM response, optional params are sent whatever the status
+func (smpp *smpp) dataSMResp(sequence uint32, msgId string, optional OptParams, status SMPPCommandStatus) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_DATA_SM_RESP
+	hdr.CmdStatus = status
+	hdr.Sequence  = sequence
+	// Create DataSM response PDU
+	pdu := new(PDUDataSMResp)
+	// Message id is null on error
+	if status == STATUS_ESME_ROK {
+		pdu.MessageId = msgId
+	}
+	pdu.Optional = optional
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = smpp.sendResp(pdu)
+	return
+}
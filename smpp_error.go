@@ -0,0 +1,140 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"fmt"
+)
+
+// Command status descriptions
+var statusText = map[SMPPCommandStatus]string{
+	STATUS_ESME_ROK:		"No Error",
+	STATUS_ESME_RINVMSGLEN:		"Message Length is invalid",
+	STATUS_ESME_RINVCMDLEN:		"Command Length is invalid",
+	STATUS_ESME_RINVCMDID:		"Invalid Command ID",
+	STATUS_ESME_RINVBNDSTS:		"Incorrect BIND Status for given command",
+	STATUS_ESME_RALYBND:		"ESME Already in Bound State",
+	STATUS_ESME_RINVPRTFLG:		"Invalid Priority Flag",
+	STATUS_ESME_RINVREGDLVFLG:	"Invalid Registered Delivery Flag",
+	STATUS_ESME_RSYSERR:		"System Error",
+	STATUS_ESME_RINVSRCADR:		"Invalid Source Address",
+	STATUS_ESME_RINVDSTADR:		"Invalid Dest Addr",
+	STATUS_ESME_RINVMSGID:		"Message ID is invalid",
+	STATUS_ESME_RBINDFAIL:		"Bind Failed",
+	STATUS_ESME_RINVPASWD:		"Invalid Password",
+	STATUS_ESME_RINVSYSID:		"Invalid System ID",
+	STATUS_ESME_RCANCELFAIL:	"Cancel SM Failed",
+	STATUS_ESME_RREPLACEFAIL:	"Replace SM Failed",
+	STATUS_ESME_RMSGQFUL:		"Message Queue Full",
+	STATUS_ESME_RINVSERTYP:		"Invalid Service Type",
+	STATUS_ESME_RINVNUMDESTS:	"Invalid number of destinations",
+	STATUS_ESME_RINVDLNAME:		"Invalid Distribution List name",
+	STATUS_ESME_RINVDESTFLAG:	"Destination flag is invalid",
+	STATUS_ESME_RINVSUBREP:		"Invalid 'submit with replace' request",
+	STATUS_ESME_RINVESMCLASS:	"Invalid esm_class field data",
+	STATUS_ESME_RCNTSUBDL:		"Cannot Submit to Distribution List",
+	STATUS_ESME_RSUBMITFAIL:	"Submit_sm or submit_multi failed",
+	STATUS_ESME_RINVSRCTON:		"Invalid Source address TON",
+	STATUS_ESME_RINVSRCNPI:		"Invalid Source address NPI",
+	STATUS_ESME_RINVDSTTON:		"Invalid Destination address TON",
+	STATUS_ESME_RINVDSTNPI:		"Invalid Destination address NPI",
+	STATUS_ESME_RINVSYSTYP:		"Invalid system_type field",
+	STATUS_ESME_RINVREPFLAG:	"Invalid replace_if_present flag",
+	STATUS_ESME_RINVNUMMSGS:	"Invalid number of messages",
+	STATUS_ESME_RTHROTTLED:		"Throttling error (ESME has exceeded allowed message limits)",
+	STATUS_ESME_RINVSCHED:		"Invalid Scheduled Delivery Time",
+	STATUS_ESME_RINVEXPIRY:		"Invalid message validity period (Expiry time)",
+	STATUS_ESME_RINVDFTMSGID:	"Predefined Message Invalid or Not Found",
+	STATUS_ESME_RX_T_APPN:		"ESME Receiver Temporary App Error Code",
+	STATUS_ESME_RX_P_APPN:		"ESME Receiver Permanent App Error Code",
+	STATUS_ESME_RX_R_APPN:		"ESME Receiver Reject Message Error Code",
+	STATUS_ESME_RQUERYFAIL:		"Query_sm request failed",
+	STATUS_ESME_RINVOPTPARSTREAM:	"Error in the optional part of the PDU Body",
+	STATUS_ESME_ROPTPARNOTALLWD:	"Optional Parameter not allowed",
+	STATUS_ESME_RINVPARLEN:		"Invalid Parameter Length",
+	STATUS_ESME_RMISSINGOPTPARAM:	"Expected Optional Parameter missing",
+	STATUS_ESME_RINVOPTPARAMVAL:	"Invalid Optional Parameter Value",
+	STATUS_ESME_RDELIVERYFAILURE:	"Delivery Failure",
+	STATUS_ESME_RUNKNOWNERR:	"Unknown Error",
+	// SMPP v5.0
+	STATUS_ESME_RSERTYPUNAUTH:	"ESME Not authorised to use specified service_type",
+	STATUS_ESME_RPROHIBITED:	"ESME Prohibited from using specified operation",
+	STATUS_ESME_RSERTYPUNAVAIL:	"Specified service_type is unavailable",
+	STATUS_ESME_RSERTYPDENIED:	"Specified service_type is denied",
+	STATUS_ESME_RINVDCS:		"Invalid Data Coding Scheme",
+	STATUS_ESME_RINVSRCADDRSUBUNIT:	"Source Address Sub unit is Invalid",
+	STATUS_ESME_RINVDSTADDRSUBUNIT:	"Destination Address Sub unit is Invalid",
+	STATUS_ESME_RINVBCASTFREQINT:	"Broadcast Frequency Interval is invalid",
+	STATUS_ESME_RINVBCASTALIAS_NAME:	"Broadcast Alias Name is invalid",
+	STATUS_ESME_RINVBCASTAREAFMT:	"Broadcast Area Format is invalid",
+	STATUS_ESME_RINVNUMBCAST_AREAS:	"Number of Broadcast Areas is invalid",
+	STATUS_ESME_RINVBCASTCNTTYPE:	"Broadcast Content Type is invalid",
+	STATUS_ESME_RINVBCASTMSGCLASS:	"Broadcast Message Class is invalid",
+	STATUS_ESME_RBCASTFAIL:		"broadcast_sm operation failed",
+	STATUS_ESME_RBCASTQUERYFAIL:	"query_broadcast_sm operation failed",
+	STATUS_ESME_RBCASTCANCELFAIL:	"cancel_broadcast_sm operation failed",
+	STATUS_ESME_RINVBCAST_REP:	"Number of Repeated Broadcasts is invalid",
+	STATUS_ESME_RINVBCASTSRVGRP:	"Broadcast Service Group is invalid",
+	STATUS_ESME_RINVBCASTCHANIND:	"Broadcast Channel Indicator is invalid",
+}
+
+// Get the command status description
+func (status SMPPCommandStatus) String() string {
+	if text, ok := statusText[status]; ok {
+		return text
+	}
+	return fmt.Sprintf("Unknown command status 0x%08x", uint32(status))
+}
+
+// Check if the command status is temporary, the request may succeed if retried later
+func (status SMPPCommandStatus) Temporary() bool {
+	switch status {
+		case STATUS_ESME_RTHROTTLED, STATUS_ESME_RMSGQFUL, STATUS_ESME_RX_T_APPN, STATUS_ESME_RSERTYPUNAVAIL:
+			return true
+	}
+	return false
+}
+
+// Check if the command status is permanent, the request should not be retried
+func (status SMPPCommandStatus) Permanent() bool {
+	return status != STATUS_ESME_ROK && !status.Temporary()
+}
+
+// SMPP error, returned when a response has a non-zero command status
+type SMPPError struct {
+	Status		SMPPCommandStatus
+	CmdId		SMPPCommand
+	Sequence	uint32
+}
+
+// Create an error from a response header
+func newSMPPError(hdr *PDUHeader) *SMPPError {
+	return &SMPPError{hdr.CmdStatus, hdr.CmdId, hdr.Sequence}
+}
+
+// Get the error description
+func (err *SMPPError) String() string {
+	return fmt.Sprintf("SMPP Error: %s (command status 0x%08x, command id 0x%08x, sequence %d)", err.Status.String(), uint32(err.Status), uint32(err.CmdId), err.Sequence)
+}
+
+// Check if the error is temporary
+func (err *SMPPError) Temporary() bool {
+	return err.Status.Temporary()
+}
+
+// Check if the error is permanent
+func (err *SMPPError) Permanent() bool {
+	return err.Status.Permanent()
+}
+
+// Optional param error, the PDU is returned with the error as the mandatory params were decoded
+type OptionalParamError struct {
+	Reason	string
+}
+
+// Get the error description
+func (err *OptionalParamError) String() string {
+	return "Optional params: " + err.Reason
+}
@@ -0,0 +1,141 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"os"
+)
+
+// GSM 03.38 escape to extension table
+const GSM_ESCAPE = 0x1b
+
+// GSM 03.38 default alphabet, indexed by septet (escape is a placeholder)
+var gsmAlphabet = []int("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà")
+
+// GSM 03.38 extension table, septet following escape
+var gsmExtension = map[byte]int{
+	0x0a: '\f',
+	0x14: '^',
+	0x28: '{',
+	0x29: '}',
+	0x2f: '\\',
+	0x3c: '[',
+	0x3d: '~',
+	0x3e: ']',
+	0x40: '|',
+	0x65: '€',
+}
+
+// Reverse lookups, rune to septet
+var (
+	gsmAlphabetRev  = make(map[int]byte)
+	gsmExtensionRev = make(map[int]byte)
+)
+
+// Build reverse lookups
+func init() {
+	for i, rune := range gsmAlphabet {
+		if i != GSM_ESCAPE {
+			gsmAlphabetRev[rune] = byte(i)
+		}
+	}
+	for septet, rune := range gsmExtension {
+		gsmExtensionRev[rune] = septet
+	}
+}
+
+// Get the number of septets required to encode text in the GSM default alphabet,
+// extension characters count as 2. ok is false if any character can't be encoded
+func GSMLength(text string) (n int, ok bool) {
+	for _, rune := range text {
+		if _, found := gsmAlphabetRev[rune]; found {
+			n ++
+		} else if _, found := gsmExtensionRev[rune]; found {
+			n += 2
+		} else {
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+// Encode text as unpacked GSM 03.38 septets (1 septet per octet)
+func EncodeGSM(text string) (p []byte, err os.Error) {
+	p = make([]byte, 0, len(text))
+	for _, rune := range text {
+		if septet, ok := gsmAlphabetRev[rune]; ok {
+			p = append(p, septet)
+		} else if septet, ok := gsmExtensionRev[rune]; ok {
+			p = append(p, GSM_ESCAPE, septet)
+		} else {
+			err = os.NewError("Encode GSM: Character " + string(rune) + " not in GSM 03.38 alphabet")
+			return nil, err
+		}
+	}
+	return
+}
+
+// Decode unpacked GSM 03.38 septets to text, unknown extension characters decode as space
+func DecodeGSM(p []byte) (text string, err os.Error) {
+	runes := make([]int, 0, len(p))
+	for i := 0; i < len(p); i ++ {
+		if p[i] > 0x7f {
+			err = os.NewError("Decode GSM: Invalid septet")
+			return "", err
+		}
+		if p[i] == GSM_ESCAPE {
+			i ++
+			if i == len(p) {
+				break
+			}
+			if rune, ok := gsmExtension[p[i]]; ok {
+				runes = append(runes, rune)
+			} else {
+				runes = append(runes, ' ')
+			}
+			continue
+		}
+		runes = append(runes, gsmAlphabet[p[i]])
+	}
+	text = string(runes)
+	return
+}
+
+// Pack septets into octets
+func PackGSM(septets []byte) (p []byte) {
+	p = make([]byte, (len(septets) * 7 + 7) / 8)
+	for i, septet := range septets {
+		bit   := i * 7
+		pos   := bit / 8
+		shift := uint(bit % 8)
+		p[pos] |= (septet & 0x7f) << shift
+		// Septet spans 2 octets
+		if shift > 1 {
+			p[pos + 1] |= (septet & 0x7f) >> (8 - shift)
+		}
+	}
+	return
+}
+
+// Unpack n septets from octets
+func UnpackGSM(p []byte, n int) (septets []byte) {
+	// Limit to the number of septets available
+	if max := len(p) * 8 / 7; n > max {
+		n = max
+	}
+	septets = make([]byte, n)
+	for i := 0; i < n; i ++ {
+		bit   := i * 7
+		pos   := bit / 8
+		shift := uint(bit % 8)
+		v := uint16(p[pos]) >> shift
+		// Septet spans 2 octets
+		if shift > 1 && pos + 1 < len(p) {
+			v |= uint16(p[pos + 1]) << (8 - shift)
+		}
+		septets[i] = byte(v & 0x7f)
+	}
+	return
+}
@@ -0,0 +1,130 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"bytes"
+	"testing"
+)
+
+// GSM 03.38 encoding tests, text and unpacked septets
+var gsmTests = []struct {
+	text	string
+	septets	[]byte
+}{
+	{"", []byte{}},
+	{"hello", []byte{0x68, 0x65, 0x6c, 0x6c, 0x6f}},
+	{"@£$¥", []byte{0x00, 0x01, 0x02, 0x03}},
+	{"ΔΦΓΛΩΠΨΣΘΞ", []byte{0x10, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a}},
+	{"ÄÖÑÜ§¿äöñüà", []byte{0x5b, 0x5c, 0x5d, 0x5e, 0x5f, 0x60, 0x7b, 0x7c, 0x7d, 0x7e, 0x7f}},
+	{"a\nb\rc", []byte{0x61, 0x0a, 0x62, 0x0d, 0x63}},
+	// Extension table
+	{"€", []byte{0x1b, 0x65}},
+	{"{}[]~\\|^\f", []byte{0x1b, 0x28, 0x1b, 0x29, 0x1b, 0x3c, 0x1b, 0x3e, 0x1b, 0x3d, 0x1b, 0x2f, 0x1b, 0x40, 0x1b, 0x14, 0x1b, 0x0a}},
+	{"5€ [ok]", []byte{0x35, 0x1b, 0x65, 0x20, 0x1b, 0x3c, 0x6f, 0x6b, 0x1b, 0x3e}},
+}
+
+func TestEncodeGSM(t *testing.T) {
+	for _, test := range gsmTests {
+		p, err := EncodeGSM(test.text)
+		if err != nil {
+			t.Errorf("EncodeGSM(%q): %s", test.text, err)
+			continue
+		}
+		if !bytes.Equal(p, test.septets) {
+			t.Errorf("EncodeGSM(%q) = %x, want %x", test.text, p, test.septets)
+		}
+	}
+}
+
+func TestDecodeGSM(t *testing.T) {
+	for _, test := range gsmTests {
+		text, err := DecodeGSM(test.septets)
+		if err != nil {
+			t.Errorf("DecodeGSM(%x): %s", test.septets, err)
+			continue
+		}
+		if text != test.text {
+			t.Errorf("DecodeGSM(%x) = %q, want %q", test.septets, text, test.text)
+		}
+	}
+}
+
+func TestGSMLength(t *testing.T) {
+	for _, test := range gsmTests {
+		n, ok := GSMLength(test.text)
+		if !ok || n != len(test.septets) {
+			t.Errorf("GSMLength(%q) = %d, %v, want %d, true", test.text, n, ok, len(test.septets))
+		}
+	}
+}
+
+// Characters outside the alphabet can't be encoded
+func TestEncodeGSMInvalid(t *testing.T) {
+	for _, text := range []string{"中文", "`", "ç"} {
+		if _, err := EncodeGSM(text); err == nil {
+			t.Errorf("EncodeGSM(%q): expected error", text)
+		}
+		if _, ok := GSMLength(text); ok {
+			t.Errorf("GSMLength(%q): expected not ok", text)
+		}
+	}
+}
+
+// Septets over 0x7f are invalid, unknown extension characters decode as space and a trailing escape is dropped
+func TestDecodeGSMInvalid(t *testing.T) {
+	if _, err := DecodeGSM([]byte{0x61, 0x80}); err == nil {
+		t.Errorf("DecodeGSM(6180): expected error")
+	}
+	tests := []struct {
+		septets	[]byte
+		text	string
+	}{
+		{[]byte{0x61, 0x1b, 0x01, 0x62}, "a b"},
+		{[]byte{0x61, 0x1b}, "a"},
+	}
+	for _, test := range tests {
+		text, err := DecodeGSM(test.septets)
+		if err != nil || text != test.text {
+			t.Errorf("DecodeGSM(%x) = %q, %v, want %q", test.septets, text, err, test.text)
+		}
+	}
+}
+
+// Packing tests, unpacked septets and packed octets
+var gsmPackTests = []struct {
+	septets	[]byte
+	packed	[]byte
+}{
+	{[]byte{}, []byte{}},
+	{[]byte{0x61}, []byte{0x61}},
+	{[]byte{0x68, 0x65, 0x6c, 0x6c, 0x6f}, []byte{0xe8, 0x32, 0x9b, 0xfd, 0x06}},
+	// 7 septets leave a 1 bit gap, 8 septets fill 7 octets
+	{[]byte{0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37}, []byte{0x31, 0xd9, 0x8c, 0x56, 0xb3, 0xdd, 0x00}},
+	{[]byte{0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38}, []byte{0x31, 0xd9, 0x8c, 0x56, 0xb3, 0xdd, 0x70}},
+	{[]byte{0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f}, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+}
+
+func TestPackGSM(t *testing.T) {
+	for _, test := range gsmPackTests {
+		p := PackGSM(test.septets)
+		if !bytes.Equal(p, test.packed) {
+			t.Errorf("PackGSM(%x) = %x, want %x", test.septets, p, test.packed)
+		}
+	}
+}
+
+func TestUnpackGSM(t *testing.T) {
+	for _, test := range gsmPackTests {
+		septets := UnpackGSM(test.packed, len(test.septets))
+		if !bytes.Equal(septets, test.septets) {
+			t.Errorf("UnpackGSM(%x, %d) = %x, want %x", test.packed, len(test.septets), septets, test.septets)
+		}
+	}
+	// Septet count is limited to the octets available
+	if septets := UnpackGSM([]byte{0x61}, 5); !bytes.Equal(septets, []byte{0x61}) {
+		t.Errorf("UnpackGSM(61, 5) = %x, want 61", septets)
+	}
+}
@@ -0,0 +1,102 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"os"
+	"time"
+)
+
+// Enable enquire link keepalive, interval and timeout are in nanoseconds (timeout defaults to interval)
+// If a response isn't received within the timeout linkDead is called and the connection closed
+func (smpp *smpp) Keepalive(interval, timeout int64, linkDead func()) (err os.Error) {
+	if interval <= 0 {
+		err = os.NewError("Keepalive: Interval must be greater than 0")
+		return
+	}
+	if timeout <= 0 {
+		timeout = interval
+	}
+	smpp.interval = interval
+	smpp.timeout  = timeout
+	smpp.linkDead = linkDead
+	// Keepalive uses the response reader
+	if !smpp.connected || !smpp.bound {
+		return
+	}
+	// Restart the loop if already reading so the new interval is used
+	smpp.mutex.Lock()
+	reading := smpp.reading
+	if reading {
+		smpp.startKeepalive()
+	}
+	smpp.mutex.Unlock()
+	if reading {
+		return
+	}
+	err = smpp.startReader()
+	return
+}
+
+// Start the keepalive loop stopping any previous loop, called with the mutex held
+func (smpp *smpp) startKeepalive() {
+	if smpp.keepaliveStop != nil {
+		close(smpp.keepaliveStop)
+	}
+	smpp.keepaliveStop = make(chan bool)
+	go smpp.keepaliveLoop(smpp.done, smpp.keepaliveStop)
+}
+
+// Send enquire link every interval until the reader stops or the loop is replaced
+func (smpp *smpp) keepaliveLoop(done, stop chan bool) {
+	ticker := time.NewTicker(smpp.interval)
+	defer ticker.Stop()
+	for {
+		select {
+			case <-done:
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+		}
+		// PDU header
+		hdr := new(PDUHeader)
+		hdr.CmdId     = CMD_ENQUIRE_LINK
+		hdr.CmdStatus = STATUS_ESME_ROK
+		// Create enquire link PDU
+		pdu := new(PDUEnquireLink)
+		pdu.setHeader(hdr)
+		// Wait for a slot in the window, a full window counts towards the timeout
+		timeout := time.After(smpp.timeout)
+		select {
+			case <-done:
+				return
+			case <-stop:
+				return
+			case smpp.inflight <- true:
+				res, err := smpp.sendInflight(pdu)
+				if err != nil {
+					return
+				}
+				// Wait for the response, any response means the link is alive
+				select {
+					case <-done:
+						return
+					case <-stop:
+						return
+					case <-res:
+						continue
+					case <-timeout:
+				}
+			case <-timeout:
+		}
+		// Link is dead
+		if smpp.linkDead != nil {
+			smpp.linkDead()
+		}
+		smpp.close()
+		return
+	}
+}
@@ -0,0 +1,111 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"os"
+	"net"
+	"bufio"
+	"strconv"
+)
+
+// Time allowed for the SMSC to outbind and respond to the bind, in nanoseconds
+const OUTBIND_TIMEOUT = 30e9
+
+// Outbind listener, accepts connections from an SMSC and binds as a receiver
+type OutbindListener struct {
+	listener	net.Listener
+	systemId	string
+	password	string
+	params		*BindParams
+	accepted	chan *Receiver
+	done		chan bool
+	err		os.Error
+}
+
+// Listen for connections, each connection is bound by its own goroutine
+func (ol *OutbindListener) listen(host string, port int) (err os.Error) {
+	ol.listener, err = net.Listen("tcp", host + ":" + strconv.Itoa(port))
+	if err != nil {
+		return
+	}
+	ol.accepted = make(chan *Receiver)
+	ol.done     = make(chan bool)
+	go ol.serve()
+	return
+}
+
+// Accept connections until the listener fails or is closed
+func (ol *OutbindListener) serve() {
+	for {
+		conn, err := ol.listener.Accept()
+		if err != nil {
+			ol.err = err
+			close(ol.done)
+			return
+		}
+		go ol.handshake(conn)
+	}
+}
+
+// Wait for the outbind and bind as a receiver, the connection is closed on failure
+func (ol *OutbindListener) handshake(conn net.Conn) {
+	// Create new receiver
+	rx := new(Receiver)
+	rx.conn      = conn
+	rx.connected = true
+	rx.reader    = bufio.NewReader(conn)
+	rx.writer    = bufio.NewWriter(conn)
+	// SMSC must outbind and bind within the timeout
+	err := conn.SetReadTimeout(OUTBIND_TIMEOUT)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	// First PDU must be an outbind
+	rpdu, err := rx.readPDU()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	outbind, ok := rpdu.(*PDUOutbind)
+	// Authenticate, the outbind has no response so the connection is closed on failure
+	if !ok || outbind.SystemId != ol.systemId || outbind.Password != ol.password {
+		conn.Close()
+		return
+	}
+	// Bind with server
+	err = rx.bind(CMD_BIND_RECEIVER, CMD_BIND_RECEIVER_RESP, ol.params)
+	if err == nil {
+		err = conn.SetReadTimeout(0)
+	}
+	if err != nil {
+		conn.Close()
+		return
+	}
+	// Pass to Accept unless the listener has stopped
+	select {
+		case ol.accepted <- rx:
+		case <-ol.done:
+			rx.close()
+	}
+}
+
+// Accept the next bound receiver, only listener errors are returned
+// The SMSC initiates the connection so the receiver can't reconnect
+func (ol *OutbindListener) Accept() (rx *Receiver, err os.Error) {
+	select {
+		case rx = <-ol.accepted:
+		case <-ol.done:
+			err = ol.err
+	}
+	return
+}
+
+// Close the listener
+func (ol *OutbindListener) Close() (err os.Error) {
+	err = ol.listener.Close()
+	return
+}
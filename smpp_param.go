@@ -4,27 +4,387 @@
 // Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
 package smpp
 
-// Default params
-var (
-	// Bind defaults
-	defaultsBind = Params{"systemId": "", "password": "", "systemType": "", "addrTon": SMPPTypeOfNumber(TON_UNKNOWN), "addrNpi": SMPPNumericPlanIndicator(NPI_UNKNOWN), "addressRange": ""}
-	
-	// SubmitSM defaults
-	defaultsSubmitSM = Params{"serviceType": "", "sourceAddrTon": SMPPTypeOfNumber(TON_UNKNOWN), "sourceAddrNpi": SMPPNumericPlanIndicator(NPI_UNKNOWN), "sourceAddr": "", "destAddrTon": SMPPTypeOfNumber(TON_UNKNOWN), "destAddrNpi": SMPPNumericPlanIndicator(NPI_UNKNOWN), "esmClass": SMPPEsmClassESME(ESME_MSG_MODE_DEFAULT), "protocolId":	uint8(0x00), "priorityFlag": SMPPPriority(PRIORITY_NORMAL), "schedDelTime": "", "validityPeriod": "", "regDelivery":	SMPPDelivery(DELIVERY_NONE), "replaceFlag": uint8(0x00), "dataCoding": SMPPDataCoding(CODING_LATIN1), "smDefaultMsgId": uint8(0x00)}
-	
-	// SubmitMulti defaults
-	defaultsSubmitMulti = Params{"serviceType": "", "sourceAddrTon": SMPPTypeOfNumber(TON_UNKNOWN), "sourceAddrNpi": SMPPNumericPlanIndicator(NPI_UNKNOWN), "sourceAddr": "", "destAddrTon": SMPPTypeOfNumber(TON_UNKNOWN), "destAddrNpi": SMPPNumericPlanIndicator(NPI_UNKNOWN), "esmClass": SMPPEsmClassESME(ESME_MSG_MODE_DEFAULT), "protocolId":	uint8(0x00), "priorityFlag": SMPPPriority(PRIORITY_NORMAL), "schedDelTime": "", "validityPeriod": "", "regDelivery": SMPPDelivery(DELIVERY_NONE), "replaceFlag": uint8(0x00), "dataCoding": SMPPDataCoding(CODING_LATIN1), "smDefaultMsgId": uint8(0x00)}
+import (
+	"os"
+	"strconv"
 )
 
-// Params definitions
-type Params map[string]interface{}
+// Max field lengths in octets (including null terminator)
+const (
+	MAX_SYSTEM_ID_LEN	= 16
+	MAX_PASSWORD_LEN	= 9
+	MAX_SYSTEM_TYPE_LEN	= 13
+	MAX_ADDRESS_RANGE_LEN	= 41
+	MAX_SERVICE_TYPE_LEN	= 6
+	MAX_ADDR_LEN		= 21
+	MAX_DL_NAME_LEN		= 21
+	MAX_TIME_LEN		= 17
+	MAX_MESSAGE_ID_LEN	= 65
+	MAX_SHORT_MESSAGE_LEN	= 254
+)
+
+// Optional params definition
 type OptParams map[SMPPOptionalParamTag]interface{}
 
-// Merge params
-func mergeParams(params, defaults Params) (res Params) {
-	res = defaults
-	for key, val := range params {
-		res[key] = val
+// Param error, identifies the invalid field
+type ParamError struct {
+	Field	string
+	Reason	string
+}
+
+// Get the error description
+func (err *ParamError) String() string {
+	return "Invalid param " + err.Field + ": " + err.Reason
+}
+
+// Bind params
+type BindParams struct {
+	SystemId	string
+	Password	string
+	SystemType	string
+	AddrTon		SMPPTypeOfNumber
+	AddrNpi		SMPPNumericPlanIndicator
+	AddressRange	string
+	Segmentation	SMPPSegmentation
+	InterfaceVersion	uint8
+}
+
+// Create bind params with defaults
+func NewBindParams() *BindParams {
+	return &BindParams{AddrTon: TON_UNKNOWN, AddrNpi: NPI_UNKNOWN, InterfaceVersion: SMPP_INTERFACE_VER}
+}
+
+// Validate bind params
+func (params *BindParams) Validate() (err os.Error) {
+	if err = checkCString("SystemId", params.SystemId, MAX_SYSTEM_ID_LEN); err != nil {
+		return
+	}
+	if err = checkCString("Password", params.Password, MAX_PASSWORD_LEN); err != nil {
+		return
+	}
+	if err = checkCString("SystemType", params.SystemType, MAX_SYSTEM_TYPE_LEN); err != nil {
+		return
+	}
+	if err = checkCString("AddressRange", params.AddressRange, MAX_ADDRESS_RANGE_LEN); err != nil {
+		return
+	}
+	if params.Segmentation > SEGMENT_SAR {
+		err = &ParamError{"Segmentation", "unknown segmentation mode"}
+		return
+	}
+	// Zero uses the default version
+	switch params.InterfaceVersion {
+		case 0, SMPP_INTERFACE_VER_33, SMPP_INTERFACE_VER_34, SMPP_INTERFACE_VER_50:
+		default:
+			err = &ParamError{"InterfaceVersion", "must be 0x33, 0x34 or 0x50"}
+			return
+	}
+	// SAR segmentation uses optional params
+	if params.InterfaceVersion == SMPP_INTERFACE_VER_33 && params.Segmentation == SEGMENT_SAR {
+		err = &ParamError{"Segmentation", "SAR is not supported by interface version 3.3"}
+	}
+	return
+}
+
+// SubmitSM params (also used for SubmitMulti)
+type SubmitSMParams struct {
+	ServiceType	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+	DestAddrTon	SMPPTypeOfNumber
+	DestAddrNpi	SMPPNumericPlanIndicator
+	EsmClass	SMPPEsmClassESME
+	ProtocolId	uint8
+	PriorityFlag	SMPPPriority
+	SchedDelTime	string
+	ValidityPeriod	string
+	RegDelivery	SMPPDelivery
+	ReplaceFlag	uint8
+	DataCoding	SMPPDataCoding
+	SmDefaultMsgId	uint8
+}
+
+// Create SubmitSM params with defaults
+func NewSubmitSMParams() *SubmitSMParams {
+	return &SubmitSMParams{PriorityFlag: PRIORITY_NORMAL, DataCoding: CODING_LATIN1}
+}
+
+// Validate SubmitSM params
+func (params *SubmitSMParams) Validate() (err os.Error) {
+	if err = checkCString("ServiceType", params.ServiceType, MAX_SERVICE_TYPE_LEN); err != nil {
+		return
+	}
+	if err = checkCString("SourceAddr", params.SourceAddr, MAX_ADDR_LEN); err != nil {
+		return
+	}
+	if params.PriorityFlag > PRIORITY_VERY_URGENT {
+		err = &ParamError{"PriorityFlag", "must be between 0 and 3"}
+		return
+	}
+	if err = checkTime("SchedDelTime", params.SchedDelTime); err != nil {
+		return
+	}
+	if err = checkTime("ValidityPeriod", params.ValidityPeriod); err != nil {
+		return
+	}
+	if params.ReplaceFlag > 1 {
+		err = &ParamError{"ReplaceFlag", "must be 0 or 1"}
+	}
+	return
+}
+
+// DeliverSM params
+type DeliverSMParams struct {
+	ServiceType	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	DestAddrTon	SMPPTypeOfNumber
+	DestAddrNpi	SMPPNumericPlanIndicator
+	EsmClass	SMPPEsmClassSMSC
+	ProtocolId	uint8
+	PriorityFlag	SMPPPriority
+	RegDelivery	SMPPDelivery
+	DataCoding	SMPPDataCoding
+}
+
+// Create DeliverSM params with defaults
+func NewDeliverSMParams() *DeliverSMParams {
+	return &DeliverSMParams{PriorityFlag: PRIORITY_NORMAL, DataCoding: CODING_LATIN1}
+}
+
+// Validate DeliverSM params
+func (params *DeliverSMParams) Validate() (err os.Error) {
+	if err = checkCString("ServiceType", params.ServiceType, MAX_SERVICE_TYPE_LEN); err != nil {
+		return
+	}
+	if params.PriorityFlag > PRIORITY_VERY_URGENT {
+		err = &ParamError{"PriorityFlag", "must be between 0 and 3"}
+	}
+	return
+}
+
+// DataSM params
+type DataSMParams struct {
+	ServiceType	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+	DestAddrTon	SMPPTypeOfNumber
+	DestAddrNpi	SMPPNumericPlanIndicator
+	EsmClass	SMPPEsmClassESME
+	RegDelivery	SMPPDelivery
+	DataCoding	SMPPDataCoding
+}
+
+// Create DataSM params with defaults
+func NewDataSMParams() *DataSMParams {
+	return &DataSMParams{DataCoding: CODING_LATIN1}
+}
+
+// Validate DataSM params
+func (params *DataSMParams) Validate() (err os.Error) {
+	if err = checkCString("ServiceType", params.ServiceType, MAX_SERVICE_TYPE_LEN); err != nil {
+		return
+	}
+	err = checkCString("SourceAddr", params.SourceAddr, MAX_ADDR_LEN)
+	return
+}
+
+// QuerySM params
+type QuerySMParams struct {
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+}
+
+// Create QuerySM params with defaults
+func NewQuerySMParams() *QuerySMParams {
+	return &QuerySMParams{SourceAddrTon: TON_UNKNOWN, SourceAddrNpi: NPI_UNKNOWN}
+}
+
+// Validate QuerySM params
+func (params *QuerySMParams) Validate() (err os.Error) {
+	err = checkCString("SourceAddr", params.SourceAddr, MAX_ADDR_LEN)
+	return
+}
+
+// CancelSM params
+type CancelSMParams struct {
+	ServiceType	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+	DestAddrTon	SMPPTypeOfNumber
+	DestAddrNpi	SMPPNumericPlanIndicator
+	DestAddr	string
+}
+
+// Create CancelSM params with defaults
+func NewCancelSMParams() *CancelSMParams {
+	return &CancelSMParams{SourceAddrTon: TON_UNKNOWN, SourceAddrNpi: NPI_UNKNOWN, DestAddrTon: TON_UNKNOWN, DestAddrNpi: NPI_UNKNOWN}
+}
+
+// Validate CancelSM params
+func (params *CancelSMParams) Validate() (err os.Error) {
+	if err = checkCString("ServiceType", params.ServiceType, MAX_SERVICE_TYPE_LEN); err != nil {
+		return
+	}
+	if err = checkCString("SourceAddr", params.SourceAddr, MAX_ADDR_LEN); err != nil {
+		return
+	}
+	err = checkCString("DestAddr", params.DestAddr, MAX_ADDR_LEN)
+	return
+}
+
+// BroadcastSM params, the broadcast area, content type, repetitions and frequency are required
+type BroadcastSMParams struct {
+	ServiceType	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+	PriorityFlag	SMPPPriority
+	SchedDelTime	string
+	ValidityPeriod	string
+	ReplaceFlag	uint8
+	DataCoding	SMPPDataCoding
+	SmDefaultMsgId	uint8
+	AreaIdentifier	[]byte
+	ContentType	[]byte
+	RepNum		uint16
+	FrequencyInterval	[]byte
+}
+
+// Create BroadcastSM params with defaults
+func NewBroadcastSMParams() *BroadcastSMParams {
+	return &BroadcastSMParams{SourceAddrTon: TON_UNKNOWN, SourceAddrNpi: NPI_UNKNOWN, PriorityFlag: PRIORITY_NORMAL, DataCoding: CODING_LATIN1}
+}
+
+// Validate BroadcastSM params
+func (params *BroadcastSMParams) Validate() (err os.Error) {
+	if err = checkCString("ServiceType", params.ServiceType, MAX_SERVICE_TYPE_LEN); err != nil {
+		return
+	}
+	if err = checkCString("SourceAddr", params.SourceAddr, MAX_ADDR_LEN); err != nil {
+		return
+	}
+	if params.PriorityFlag > PRIORITY_VERY_URGENT {
+		err = &ParamError{"PriorityFlag", "must be between 0 and 3"}
+		return
+	}
+	if err = checkTime("SchedDelTime", params.SchedDelTime); err != nil {
+		return
+	}
+	if err = checkTime("ValidityPeriod", params.ValidityPeriod); err != nil {
+		return
+	}
+	if params.ReplaceFlag > 1 {
+		err = &ParamError{"ReplaceFlag", "must be 0 or 1"}
+		return
+	}
+	if len(params.AreaIdentifier) == 0 || len(params.AreaIdentifier) > 100 {
+		err = &ParamError{"AreaIdentifier", "must be between 1 and 100 octets"}
+		return
+	}
+	if len(params.ContentType) != 3 {
+		err = &ParamError{"ContentType", "must be 3 octets"}
+		return
+	}
+	if len(params.FrequencyInterval) != 3 {
+		err = &ParamError{"FrequencyInterval", "must be 3 octets"}
+	}
+	return
+}
+
+// QueryBroadcastSM params
+type QueryBroadcastSMParams struct {
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+}
+
+// Create QueryBroadcastSM params with defaults
+func NewQueryBroadcastSMParams() *QueryBroadcastSMParams {
+	return &QueryBroadcastSMParams{SourceAddrTon: TON_UNKNOWN, SourceAddrNpi: NPI_UNKNOWN}
+}
+
+// Validate QueryBroadcastSM params
+func (params *QueryBroadcastSMParams) Validate() (err os.Error) {
+	err = checkCString("SourceAddr", params.SourceAddr, MAX_ADDR_LEN)
+	return
+}
+
+// CancelBroadcastSM params
+type CancelBroadcastSMParams struct {
+	ServiceType	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+}
+
+// Create CancelBroadcastSM params with defaults
+func NewCancelBroadcastSMParams() *CancelBroadcastSMParams {
+	return &CancelBroadcastSMParams{SourceAddrTon: TON_UNKNOWN, SourceAddrNpi: NPI_UNKNOWN}
+}
+
+// Validate CancelBroadcastSM params
+func (params *CancelBroadcastSMParams) Validate() (err os.Error) {
+	if err = checkCString("ServiceType", params.ServiceType, MAX_SERVICE_TYPE_LEN); err != nil {
+		return
+	}
+	err = checkCString("SourceAddr", params.SourceAddr, MAX_ADDR_LEN)
+	return
+}
+
+// ReplaceSM params, DataCoding is the coding of the original message and is only used to encode the new text
+type ReplaceSMParams struct {
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+	SchedDelTime	string
+	ValidityPeriod	string
+	RegDelivery	SMPPDelivery
+	SmDefaultMsgId	uint8
+	DataCoding	SMPPDataCoding
+}
+
+// Create ReplaceSM params with defaults
+func NewReplaceSMParams() *ReplaceSMParams {
+	return &ReplaceSMParams{SourceAddrTon: TON_UNKNOWN, SourceAddrNpi: NPI_UNKNOWN, DataCoding: CODING_LATIN1}
+}
+
+// Validate ReplaceSM params
+func (params *ReplaceSMParams) Validate() (err os.Error) {
+	if err = checkCString("SourceAddr", params.SourceAddr, MAX_ADDR_LEN); err != nil {
+		return
+	}
+	if err = checkTime("SchedDelTime", params.SchedDelTime); err != nil {
+		return
+	}
+	err = checkTime("ValidityPeriod", params.ValidityPeriod)
+	return
+}
+
+// Check a C-Octet String fits in max octets including the null terminator
+func checkCString(field, val string, max int) (err os.Error) {
+	if len(val) + 1 > max {
+		err = &ParamError{field, "exceeds " + strconv.Itoa(max - 1) + " characters"}
+	}
+	return
+}
+
+// Check a time field is null or 16 characters
+func checkTime(field, val string) (err os.Error) {
+	if len(val) != 0 && len(val) + 1 != MAX_TIME_LEN {
+		err = &ParamError{field, "must be null or " + strconv.Itoa(MAX_TIME_LEN - 1) + " characters"}
+	}
+	return
+}
+
+// Check a short message fits in the short_message field
+func checkShortMessage(field, msg string) (err os.Error) {
+	if len(msg) > MAX_SHORT_MESSAGE_LEN {
+		err = &ParamError{field, "exceeds " + strconv.Itoa(MAX_SHORT_MESSAGE_LEN) + " octets"}
 	}
 	return
 }
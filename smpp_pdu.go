@@ -6,9 +6,11 @@ package smpp
 
 import (
 	"os"
+	"io"
+	"bytes"
 	"bufio"
+	"io/ioutil"
 	"reflect"
-	"fmt"
 )
 
 // PDU interface which all PDU types should implement
@@ -22,18 +24,199 @@ type PDU interface {
 	// Set the packet header
 	setHeader(hdr *PDUHeader)
 	
+	// Set the optional params
+	setOptional(optional OptParams)
+	
+	// Get the optional params
+	getOptional() OptParams
+	
 	// Get the packet header
 	GetHeader() *PDUHeader
 	
 	// Get the struct
 	GetStruct() interface{}
+	
+	// Marshal the PDU to bytes
+	MarshalBinary() ([]byte, os.Error)
+	
+	// Unmarshal the PDU from bytes
+	UnmarshalBinary(p []byte) os.Error
+}
+
+// Create an empty PDU for a command id, returns nil for unhandled commands
+func newPDU(cmd SMPPCommand) (pdu PDU) {
+	switch cmd {
+		case CMD_GENERIC_NACK:
+			pdu = new(PDUGenericNack)
+		case CMD_BIND_RECEIVER, CMD_BIND_TRANSMITTER, CMD_BIND_TRANSCEIVER:
+			pdu = new(PDUBind)
+		case CMD_BIND_RECEIVER_RESP, CMD_BIND_TRANSMITTER_RESP, CMD_BIND_TRANSCEIVER_RESP:
+			pdu = new(PDUBindResp)
+		case CMD_OUTBIND:
+			pdu = new(PDUOutbind)
+		case CMD_UNBIND:
+			pdu = new(PDUUnbind)
+		case CMD_UNBIND_RESP:
+			pdu = new(PDUUnbindResp)
+		case CMD_ENQUIRE_LINK:
+			pdu = new(PDUEnquireLink)
+		case CMD_ENQUIRE_LINK_RESP:
+			pdu = new(PDUEnquireLinkResp)
+		case CMD_SUBMIT_SM:
+			pdu = new(PDUSubmitSM)
+		case CMD_SUBMIT_SM_RESP:
+			pdu = new(PDUSubmitSMResp)
+		case CMD_SUBMIT_MULTI:
+			pdu = new(PDUSubmitMulti)
+		case CMD_SUBMIT_MULTI_RESP:
+			pdu = new(PDUSubmitMultiResp)
+		case CMD_DELIVER_SM:
+			pdu = new(PDUDeliverSM)
+		case CMD_DELIVER_SM_RESP:
+			pdu = new(PDUDeliverSMResp)
+		case CMD_QUERY_SM:
+			pdu = new(PDUQuerySM)
+		case CMD_QUERY_SM_RESP:
+			pdu = new(PDUQuerySMResp)
+		case CMD_CANCEL_SM:
+			pdu = new(PDUCancelSM)
+		case CMD_CANCEL_SM_RESP:
+			pdu = new(PDUCancelSMResp)
+		case CMD_REPLACE_SM:
+			pdu = new(PDUReplaceSM)
+		case CMD_REPLACE_SM_RESP:
+			pdu = new(PDUReplaceSMResp)
+		case CMD_DATA_SM:
+			pdu = new(PDUDataSM)
+		case CMD_DATA_SM_RESP:
+			pdu = new(PDUDataSMResp)
+		case CMD_BROADCAST_SM:
+			pdu = new(PDUBroadcastSM)
+		case CMD_BROADCAST_SM_RESP:
+			pdu = new(PDUBroadcastSMResp)
+		case CMD_QUERY_BROADCAST_SM:
+			pdu = new(PDUQueryBroadcastSM)
+		case CMD_QUERY_BROADCAST_SM_RESP:
+			pdu = new(PDUQueryBroadcastSMResp)
+		case CMD_CANCEL_BROADCAST_SM:
+			pdu = new(PDUCancelBroadcastSM)
+		case CMD_CANCEL_BROADCAST_SM_RESP:
+			pdu = new(PDUCancelBroadcastSMResp)
+	}
+	return
+}
+
+// Decode a PDU body
+func decodePDU(hdr *PDUHeader, p []byte) (pdu PDU, err os.Error) {
+	// Create PDU
+	pdu = newPDU(hdr.CmdId)
+	if pdu == nil {
+		err = os.NewError("Decode PDU: Unknown or unhandled PDU received")
+		return nil, err
+	}
+	pdu.setHeader(hdr)
+	err = readBody(pdu, p)
+	if _, ok := err.(*OptionalParamError); err != nil && !ok {
+		return nil, err
+	}
+	return
+}
+
+// Read a PDU body, any bytes remaining after the mandatory params are Optional params
+// Invalid optional params return an OptionalParamError, the mandatory params and any optional params before the error are kept
+func readBody(pdu PDU, p []byte) (err os.Error) {
+	// Error responses may not include a body
+	if len(p) == 0 {
+		return
+	}
+	r := bufio.NewReader(bytes.NewBuffer(p))
+	err = pdu.read(r)
+	if err != nil {
+		return
+	}
+	rest, err := ioutil.ReadAll(r)
+	if err != nil || len(rest) == 0 {
+		return
+	}
+	optional, err := parseOptional(rest)
+	pdu.setOptional(optional)
+	if err != nil {
+		err = &OptionalParamError{err.String()}
+	}
+	return
+}
+
+// Decode a PDU from bytes, the PDU type is determined by the command id
+// The PDU is returned with an OptionalParamError if only the optional params are invalid
+func DecodePDU(p []byte) (pdu PDU, err os.Error) {
+	hdr, body, err := splitPDU(p)
+	if err != nil {
+		return
+	}
+	return decodePDU(hdr, body)
+}
+
+// Split bytes into the PDU header and body
+func splitPDU(p []byte) (hdr *PDUHeader, body []byte, err os.Error) {
+	if len(p) < 16 {
+		err = os.NewError("Decode PDU: Header requires 16 bytes")
+		return
+	}
+	hdr = new(PDUHeader)
+	err = hdr.read(bufio.NewReader(bytes.NewBuffer(p[0:16])))
+	if err != nil {
+		return nil, nil, err
+	}
+	if hdr.CmdLength < 16 || uint32(len(p)) < hdr.CmdLength {
+		err = os.NewError("Decode PDU: Invalid command length")
+		return nil, nil, err
+	}
+	body = p[16:hdr.CmdLength]
+	return
+}
+
+// Marshal a PDU to bytes
+func marshalPDU(pdu PDU) (p []byte, err os.Error) {
+	if pdu.GetHeader() == nil {
+		err = os.NewError("Encode PDU: Header is required")
+		return
+	}
+	buf := new(bytes.Buffer)
+	w := bufio.NewWriter(buf)
+	err = pdu.write(w)
+	if err != nil {
+		return
+	}
+	err = w.Flush()
+	if err != nil {
+		return
+	}
+	p = buf.Bytes()
+	return
+}
+
+// Unmarshal a PDU from bytes, the command id must match the PDU type
+func unmarshalPDU(pdu PDU, p []byte) (err os.Error) {
+	hdr, body, err := splitPDU(p)
+	if err != nil {
+		return
+	}
+	if reflect.Typeof(newPDU(hdr.CmdId)) != reflect.Typeof(pdu) {
+		err = os.NewError("Decode PDU: Command id does not match the PDU type")
+		return
+	}
+	// Reset the PDU so fields and optional params from a previous use don't survive
+	v := reflect.NewValue(pdu).(*reflect.PtrValue).Elem()
+	v.SetValue(reflect.MakeZero(v.Type()))
+	pdu.setHeader(hdr)
+	err = readBody(pdu, body)
+	return
 }
 
 // Common PDU functions & fields
 type PDUCommon struct {
 	Header		*PDUHeader
 	Optional	OptParams
-	OptionalLen	uint32
 }
 
 // Set header
@@ -51,33 +234,67 @@ func (pdu *PDUCommon) GetStruct() interface{} {
 	return *pdu
 }
 
-// Write Optional Params
-func (pdu *PDUCommon) writeOptional(w *bufio.Writer) (err os.Error) {
-	if len(pdu.Optional) > 0 {
-		for key, val := range pdu.Optional {
-			op := new(pduOptParam)
-			op.tag = uint16(key)
-			op.value = val
-			v := reflect.NewValue(val)
-			switch t := v.(type) {
-				case *reflect.StringValue:
-					op.length = uint16(len(val.(string)))
-				case *reflect.BoolValue:
-					op.length = 1
-				case *reflect.Uint8Value:
-					op.length = 1
-				case *reflect.Uint16Value:
-					op.length = 2
-				case *reflect.Uint32Value:
-					op.length = 4
-				case *reflect.Uint64Value:
-					op.length = 8
-			}
-			err = op.write(w)
-			if err != nil {
-				return
-			}
+// Write the header and body, the command length is set from the body length
+func (pdu *PDUCommon) writePDU(w *bufio.Writer, body []byte) (err os.Error) {
+	pdu.Header.CmdLength = uint32(len(body)) + 16
+	err = pdu.Header.write(w)
+	if err != nil || len(body) == 0 {
+		return
+	}
+	_, err = w.Write(body)
+	if err != nil {
+		return
+	}
+	err = w.Flush()
+	return
+}
+
+// Encode Optional Params
+func (pdu *PDUCommon) encodeOptional(b *bytes.Buffer) (err os.Error) {
+	// Ordered by tag so the encoding is deterministic
+	for _, tag := range pdu.Optional.tags() {
+		var p []byte
+		p, err = encodeParam(tag, pdu.Optional[tag])
+		if err != nil {
+			return
+		}
+		if len(p) > 0xffff {
+			err = os.NewError("Optional param length exceeds 65535 bytes")
+			return
+		}
+		b.Write(packUint(uint64(tag), 2))
+		b.Write(packUint(uint64(len(p)), 2))
+		b.Write(p)
+	}
+	return
+}
+
+// Set Optional Params
+func (pdu *PDUCommon) setOptional(optional OptParams) {
+	pdu.Optional = optional
+}
+
+// Get Optional Params
+func (pdu *PDUCommon) getOptional() OptParams {
+	return pdu.Optional
+}
+
+// Parse Optional Params from the remaining PDU body, params before an error are returned with it
+func parseOptional(p []byte) (optional OptParams, err os.Error) {
+	optional = make(OptParams)
+	for len(p) > 0 {
+		if len(p) < 4 {
+			err = os.NewError("Optional param header exceeds PDU length")
+			return
+		}
+		tag    := SMPPOptionalParamTag(unpackUint(p[0:2]))
+		length := int(unpackUint(p[2:4]))
+		if 4 + length > len(p) {
+			err = os.NewError("Optional param length exceeds PDU length")
+			return
 		}
+		optional[tag] = decodeParam(tag, p[4:4 + length])
+		p = p[4 + length:]
 	}
 	return
 }
@@ -158,57 +375,30 @@ func (pdu *PDUBind) read(r *bufio.Reader) (err os.Error) {
 
 // Write Bind PDU
 func (pdu *PDUBind) write(w *bufio.Writer) (err os.Error) {
-	// Write Header
-	err = pdu.Header.write(w)
-	if err != nil {
-		err = os.NewError("Bind: Error writing Header")
-		return
-	}
-	// Create byte array the size of the PDU
-	p := make([]byte, pdu.Header.CmdLength - pdu.OptionalLen - 16)
-	pos := 0
+	// Encode body
+	b := new(bytes.Buffer)
 	// Copy system id
-	if len(pdu.SystemId) > 0 {
-		copy(p[pos:len(pdu.SystemId)], []byte(pdu.SystemId))
-		pos += len(pdu.SystemId)
-	}
-	pos ++ // Null terminator
+	b.WriteString(pdu.SystemId)
+	b.WriteByte(0x00) // Null terminator
 	// Copy Password
-	if len(pdu.Password) > 0 {
-		copy(p[pos:pos + len(pdu.Password)], []byte(pdu.Password))
-		pos += len(pdu.Password)
-	}
-	pos ++ // Null terminator
+	b.WriteString(pdu.Password)
+	b.WriteByte(0x00) // Null terminator
 	// Copy system type
-	if len(pdu.SystemType) > 0 {
-		copy(p[pos:pos + len(pdu.SystemType)], []byte(pdu.SystemType))
-		pos += len(pdu.SystemType)
-	}
-	pos ++ // Null terminator
+	b.WriteString(pdu.SystemType)
+	b.WriteByte(0x00) // Null terminator
 	// Add interface version
-	p[pos] = byte(pdu.IfVersion)
-	pos ++
+	b.WriteByte(byte(pdu.IfVersion))
 	// Add TON
-	p[pos] = byte(pdu.AddrTon)
-	pos ++
+	b.WriteByte(byte(pdu.AddrTon))
 	// Add NPI
-	p[pos] = byte(pdu.AddrNpi)
-	pos ++
+	b.WriteByte(byte(pdu.AddrNpi))
 	// Copy Address range
-	if len(pdu.AddressRange) > 0 {
-		copy(p[pos:pos + len(pdu.AddressRange)], []byte(pdu.AddressRange))
-		pos += len(pdu.AddressRange)
-	}
-	// Write to buffer
-	_, err = w.Write(p)
+	b.WriteString(pdu.AddressRange)
+	b.WriteByte(0x00) // Null terminator
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
 	if err != nil {
 		err = os.NewError("Bind: Error writing to buffer")
-		return
-	}
-	// Flush write buffer
-	err = w.Flush()
-	if err != nil {
-		err = os.NewError("Bind: Error flushing write buffer")
 	}
 	return
 }
@@ -218,6 +408,16 @@ func (pdu *PDUBind) GetStruct() interface{} {
 	return *pdu
 }
 
+// Marshal Bind PDU to bytes
+func (pdu *PDUBind) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal Bind PDU from bytes
+func (pdu *PDUBind) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
 // Bind Response PDU
 type PDUBindResp struct {
 	PDUCommon
@@ -235,51 +435,26 @@ func (pdu *PDUBindResp) read(r *bufio.Reader) (err os.Error) {
 	if len(line) > 1 {
 		pdu.SystemId = string(line[0:len(line) - 1])
 	}
-	// Read Optional param
-	if pdu.Header.CmdLength > uint32(len(pdu.SystemId)) + 17 {
-		op := new(pduOptParam)
-		err = op.read(r)
-		if err != nil {
-			err = os.NewError("Bind Response: Error reading Optional param")
-			return
-		}
-		pdu.Optional = OptParams{SMPPOptionalParamTag(op.tag): op.value}
-	}
 	return
 }
 
 // Write Bind Response PDU
 func (pdu *PDUBindResp) write(w *bufio.Writer) (err os.Error) {
-	// Write Header
-	err = pdu.Header.write(w)
-	if err != nil {
-		err = os.NewError("Bind Response: Error writing Header")
-		return
-	}
-	// Create byte array the size of the PDU
-	p := make([]byte, pdu.Header.CmdLength - pdu.OptionalLen - 16)
-	pos := 0
+	// Encode body
+	b := new(bytes.Buffer)
 	// Copy system id
-	if len(pdu.SystemId) > 0 {
-		copy(p[pos:len(pdu.SystemId)], []byte(pdu.SystemId))
-		pos += len(pdu.SystemId)
-	}
-	pos ++ // Null terminator
-	// Write to buffer
-	_, err = w.Write(p)
+	b.WriteString(pdu.SystemId)
+	b.WriteByte(0x00) // Null terminator
+	// Optional params
+	err = pdu.encodeOptional(b)
 	if err != nil {
-		err = os.NewError("Bind Response: Error writing to buffer")
+		err = os.NewError("Bind Response: Error writing optional params")
 		return
 	}
-	// Flush write buffer
-	err = w.Flush()
-	if err != nil {
-		err = os.NewError("Bind Response: Error flushing write buffer")
-	}
-	// Optional params
-	err = pdu.writeOptional(w)
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
 	if err != nil {
-		err = os.NewError("Bind Response: Error writing optional params")
+		err = os.NewError("Bind Response: Error writing to buffer")
 	}
 	return
 }
@@ -289,6 +464,16 @@ func (pdu *PDUBindResp) GetStruct() interface{} {
 	return *pdu
 }
 
+// Marshal Bind Response PDU to bytes
+func (pdu *PDUBindResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal Bind Response PDU from bytes
+func (pdu *PDUBindResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
 // Unbind PDU
 type PDUUnbind struct {
 	PDUCommon
@@ -301,10 +486,17 @@ func (pdu *PDUUnbind) read(r *bufio.Reader) (err os.Error) {
 
 // Write Unbind PDU
 func (pdu *PDUUnbind) write(w *bufio.Writer) (err os.Error) {
-	// Write Header
-	err = pdu.Header.write(w)
+	// No mandatory params, any optional params are the body
+	b := new(bytes.Buffer)
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("Unbind: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
 	if err != nil {
-		err = os.NewError("Unbind: Error writing Header")
+		err = os.NewError("Unbind: Error writing to buffer")
 	}
 	return
 }
@@ -314,6 +506,16 @@ func (pdu *PDUUnbind) GetStruct() interface{} {
 	return *pdu
 }
 
+// Marshal Unbind PDU to bytes
+func (pdu *PDUUnbind) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal Unbind PDU from bytes
+func (pdu *PDUUnbind) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
 // Unbind Response PDU
 type PDUUnbindResp struct {
 	PDUCommon
@@ -326,10 +528,17 @@ func (pdu *PDUUnbindResp) read(r *bufio.Reader) (err os.Error) {
 
 // Write Unbind Response PDU
 func (pdu *PDUUnbindResp) write(w *bufio.Writer) (err os.Error) {
-	// Write Header
-	err = pdu.Header.write(w)
+	// No mandatory params, any optional params are the body
+	b := new(bytes.Buffer)
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("Unbind Response: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
 	if err != nil {
-		err = os.NewError("Unbind Response: Error writing Header")
+		err = os.NewError("Unbind Response: Error writing to buffer")
 	}
 	return
 }
@@ -339,183 +548,110 @@ func (pdu *PDUUnbindResp) GetStruct() interface{} {
 	return *pdu
 }
 
-// Submit SM PDU
-type PDUSubmitSM struct {
+// Marshal Unbind Response PDU to bytes
+func (pdu *PDUUnbindResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal Unbind Response PDU from bytes
+func (pdu *PDUUnbindResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// EnquireLink PDU
+type PDUEnquireLink struct {
 	PDUCommon
-	ServiceType	string
-	SourceAddrTon	SMPPTypeOfNumber
-	SourceAddrNpi	SMPPNumericPlanIndicator
-	SourceAddr	string
-	DestAddrTon	SMPPTypeOfNumber
-	DestAddrNpi	SMPPNumericPlanIndicator
-	DestAddr	string
-	EsmClass	SMPPEsmClassESME
-	ProtocolId	uint8
-	PriorityFlag	SMPPPriority
-	SchedDelTime	string
-	ValidityPeriod	string
-	RegDelivery	SMPPDelivery
-	ReplaceFlag	uint8
-	DataCoding	SMPPDataCoding
-	SmDefaultMsgId	uint8
-	SmLength	uint8
-	ShortMessage	string
 }
 
-// Read SubmitSM PDU
-func (pdu *PDUSubmitSM) read(r *bufio.Reader) (err os.Error) {
+// Read EnquireLink PDU
+func (pdu *PDUEnquireLink) read(r *bufio.Reader) (err os.Error) {
 	return
 }
 
-// Write SubmitSM PDU
-func (pdu *PDUSubmitSM) write(w *bufio.Writer) (err os.Error) {
-	// Write Header
-	err = pdu.Header.write(w)
-	if err != nil {
-		err = os.NewError("SubmitSM: Error writing Header")
-		return
-	}
-	// Create byte array the size of the PDU
-	p := make([]byte, pdu.Header.CmdLength - pdu.OptionalLen - 16)
-	pos := 0
-	// Copy service type
-	if len(pdu.ServiceType) > 0 {
-		copy(p[pos:len(pdu.ServiceType)], []byte(pdu.ServiceType))
-		pos += len(pdu.ServiceType)
-	}
-	pos ++ // Null terminator
-	// Source TON
-	p[pos] = byte(pdu.SourceAddrTon)
-	pos ++
-	// Source NPI
-	p[pos] = byte(pdu.SourceAddrNpi)
-	pos ++
-	// Source Address
-	if len(pdu.SourceAddr) > 0 {
-		copy(p[pos:pos + len(pdu.SourceAddr)], []byte(pdu.SourceAddr))
-		pos += len(pdu.SourceAddr)
-	}
-	pos ++ // Null terminator
-	// Destination TON
-	p[pos] = byte(pdu.DestAddrTon)
-	pos ++
-	// Destination NPI
-	p[pos] = byte(pdu.DestAddrNpi)
-	pos ++
-	// Destination Address
-	if len(pdu.DestAddr) > 0 {
-		copy(p[pos:pos + len(pdu.DestAddr)], []byte(pdu.DestAddr))
-		pos += len(pdu.DestAddr)
-	}
-	pos ++ // Null terminator
-	// ESM Class
-	p[pos] = byte(pdu.EsmClass)
-	pos ++
-	// Protocol Id
-	p[pos] = byte(pdu.ProtocolId)
-	pos ++
-	// Priority Flag
-	p[pos] = byte(pdu.PriorityFlag)
-	pos ++
-	// Sheduled Delivery Time
-	if len(pdu.SchedDelTime) > 0 {
-		copy(p[pos:pos + len(pdu.SchedDelTime)], []byte(pdu.SchedDelTime))
-		pos += len(pdu.SchedDelTime)
-	}
-	pos ++ // Null terminator
-	// Validity Period
-	if len(pdu.ValidityPeriod) > 0 {
-		copy(p[pos:pos + len(pdu.ValidityPeriod)], []byte(pdu.ValidityPeriod))
-		pos += len(pdu.ValidityPeriod)
-	}
-	pos ++ // Null terminator
-	// Registered Delivery
-	p[pos] = byte(pdu.RegDelivery)
-	pos ++
-	// Replace Flag
-	p[pos] = byte(pdu.ReplaceFlag)
-	pos ++
-	// Data Coding
-	p[pos] = byte(pdu.DataCoding)
-	pos ++
-	// Default Msg Id
-	p[pos] = byte(pdu.SmDefaultMsgId)
-	pos ++
-	// Msg Length
-	p[pos] = byte(pdu.SmLength)
-	pos ++
-	// Message
-	if len(pdu.ShortMessage) > 0 {
-		copy(p[pos:pos + len(pdu.ShortMessage)], []byte(pdu.ShortMessage))
-		pos += len(pdu.ShortMessage)
-	}
-	// Write to buffer
-	_, err = w.Write(p)
-	if err != nil {
-		err = os.NewError("SubmitSM: Error writing to buffer")
-		return
-	}
-	// Flush write buffer
-	err = w.Flush()
+// Write EnquireLink PDU
+func (pdu *PDUEnquireLink) write(w *bufio.Writer) (err os.Error) {
+	// No mandatory params, any optional params are the body
+	b := new(bytes.Buffer)
+	err = pdu.encodeOptional(b)
 	if err != nil {
-		err = os.NewError("SubmitSM: Error flushing write buffer")
+		err = os.NewError("EnquireLink: Error writing optional params")
 		return
 	}
-	// Optional params
-	err = pdu.writeOptional(w)
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
 	if err != nil {
-		err = os.NewError("SubmitSM: Error writing optional params")
+		err = os.NewError("EnquireLink: Error writing to buffer")
 	}
 	return
 }
 
 // Get Struct
-func (pdu *PDUSubmitSM) GetStruct() interface{} {
+func (pdu *PDUEnquireLink) GetStruct() interface{} {
 	return *pdu
 }
 
-// SubmitSM Response PDU
-type PDUSubmitSMResp struct {
+// Marshal EnquireLink PDU to bytes
+func (pdu *PDUEnquireLink) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal EnquireLink PDU from bytes
+func (pdu *PDUEnquireLink) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// EnquireLink Response PDU
+type PDUEnquireLinkResp struct {
 	PDUCommon
-	MessageId	string
 }
 
-// Read SubmitSM Response PDU
-func (pdu *PDUSubmitSMResp) read(r *bufio.Reader) (err os.Error) {
-	// Read message id (null terminated string or null)
-	line, err := r.ReadBytes(0x00)
+// Read EnquireLink Response PDU
+func (pdu *PDUEnquireLinkResp) read(r *bufio.Reader) (err os.Error) {
+	return
+}
+
+// Write EnquireLink Response PDU
+func (pdu *PDUEnquireLinkResp) write(w *bufio.Writer) (err os.Error) {
+	// No mandatory params, any optional params are the body
+	b := new(bytes.Buffer)
+	err = pdu.encodeOptional(b)
 	if err != nil {
-		err = os.NewError("SubmitSM Response: Error reading message id")
+		err = os.NewError("EnquireLink Response: Error writing optional params")
 		return
 	}
-	if len(line) > 1 {
-		pdu.MessageId = string(line[0:len(line) - 1])
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("EnquireLink Response: Error writing to buffer")
 	}
 	return
 }
 
-// Write SubmitSM Response PDU
-func (pdu *PDUSubmitSMResp) write(r *bufio.Writer) (err os.Error) {
-	return
-}
-
 // Get Struct
-func (pdu *PDUSubmitSMResp) GetStruct() interface{} {
+func (pdu *PDUEnquireLinkResp) GetStruct() interface{} {
 	return *pdu
 }
 
-// SubmitMulti PDU
-type PDUSubmitMulti struct {
+// Marshal EnquireLink Response PDU to bytes
+func (pdu *PDUEnquireLinkResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal EnquireLink Response PDU from bytes
+func (pdu *PDUEnquireLinkResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// Submit SM PDU
+type PDUSubmitSM struct {
 	PDUCommon
 	ServiceType	string
 	SourceAddrTon	SMPPTypeOfNumber
 	SourceAddrNpi	SMPPNumericPlanIndicator
 	SourceAddr	string
-	NumOfDests	uint8
 	DestAddrTon	SMPPTypeOfNumber
 	DestAddrNpi	SMPPNumericPlanIndicator
-	DestAddrs	[]string
-	DestLists	[]string
+	DestAddr	string
 	EsmClass	SMPPEsmClassESME
 	ProtocolId	uint8
 	PriorityFlag	SMPPPriority
@@ -529,127 +665,476 @@ type PDUSubmitMulti struct {
 	ShortMessage	string
 }
 
-// Read SubmitMulti PDU
-func (pdu *PDUSubmitMulti) read(r *bufio.Reader) (err os.Error) {
-	return
-}
-
-// Write SubmitMulti PDU
-func (pdu *PDUSubmitMulti) write(w *bufio.Writer) (err os.Error) {
-	// Write Header
-	err = pdu.Header.write(w)
+// Read SubmitSM PDU
+func (pdu *PDUSubmitSM) read(r *bufio.Reader) (err os.Error) {
+	// Read service type (null terminated string or null)
+	line, err := r.ReadBytes(0x00)
 	if err != nil {
-		err = os.NewError("SubmitMulti: Error writing Header")
+		err = os.NewError("SubmitSM: Error reading service type")
 		return
 	}
-	// Create byte array the size of the PDU
-	p := make([]byte, pdu.Header.CmdLength - pdu.OptionalLen - 16)
-	pos := 0
-	// Copy service type
-	if len(pdu.ServiceType) > 0 {
-		copy(p[pos:len(pdu.ServiceType)], []byte(pdu.ServiceType))
-		pos += len(pdu.ServiceType)
+	if len(line) > 1 {
+		pdu.ServiceType = string(line[0:len(line) - 1])
+	}
+	// Read source TON/NPI
+	p := make([]byte, 2)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("SubmitSM: Error reading source TON/NPI")
+		return
+	}
+	pdu.SourceAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.SourceAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read source address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("SubmitSM: Error reading source address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SourceAddr = string(line[0:len(line) - 1])
+	}
+	// Read destination TON/NPI
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("SubmitSM: Error reading destination TON/NPI")
+		return
+	}
+	pdu.DestAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.DestAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read destination address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("SubmitSM: Error reading destination address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.DestAddr = string(line[0:len(line) - 1])
+	}
+	// Read ESM class, protocol id and priority flag
+	p = make([]byte, 3)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("SubmitSM: Error reading ESM class/protocol id/priority flag")
+		return
+	}
+	pdu.EsmClass     = SMPPEsmClassESME(p[0])
+	pdu.ProtocolId   = uint8(p[1])
+	pdu.PriorityFlag = SMPPPriority(p[2])
+	// Read scheduled delivery time (should be null)
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("SubmitSM: Error reading scheduled delivery time")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SchedDelTime = string(line[0:len(line) - 1])
+	}
+	// Read validity period (should be null)
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("SubmitSM: Error reading validity period")
+		return
+	}
+	if len(line) > 1 {
+		pdu.ValidityPeriod = string(line[0:len(line) - 1])
+	}
+	// Read registered delivery, replace flag, data coding, default msg id and msg length
+	p = make([]byte, 5)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("SubmitSM: Error reading message options")
+		return
+	}
+	pdu.RegDelivery    = SMPPDelivery(p[0])
+	pdu.ReplaceFlag    = uint8(p[1])
+	pdu.DataCoding     = SMPPDataCoding(p[2])
+	pdu.SmDefaultMsgId = uint8(p[3])
+	pdu.SmLength       = uint8(p[4])
+	// Read message
+	if pdu.SmLength > 0 {
+		p = make([]byte, pdu.SmLength)
+		_, err = io.ReadFull(r, p)
+		if err != nil {
+			err = os.NewError("SubmitSM: Error reading message")
+			return
+		}
+		pdu.ShortMessage = string(p)
+	}
+	return
+}
+
+// Write SubmitSM PDU
+func (pdu *PDUSubmitSM) write(w *bufio.Writer) (err os.Error) {
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy service type
+	b.WriteString(pdu.ServiceType)
+	b.WriteByte(0x00) // Null terminator
+	// Source TON
+	b.WriteByte(byte(pdu.SourceAddrTon))
+	// Source NPI
+	b.WriteByte(byte(pdu.SourceAddrNpi))
+	// Source Address
+	b.WriteString(pdu.SourceAddr)
+	b.WriteByte(0x00) // Null terminator
+	// Destination TON
+	b.WriteByte(byte(pdu.DestAddrTon))
+	// Destination NPI
+	b.WriteByte(byte(pdu.DestAddrNpi))
+	// Destination Address
+	b.WriteString(pdu.DestAddr)
+	b.WriteByte(0x00) // Null terminator
+	// ESM Class
+	b.WriteByte(byte(pdu.EsmClass))
+	// Protocol Id
+	b.WriteByte(byte(pdu.ProtocolId))
+	// Priority Flag
+	b.WriteByte(byte(pdu.PriorityFlag))
+	// Sheduled Delivery Time
+	b.WriteString(pdu.SchedDelTime)
+	b.WriteByte(0x00) // Null terminator
+	// Validity Period
+	b.WriteString(pdu.ValidityPeriod)
+	b.WriteByte(0x00) // Null terminator
+	// Registered Delivery
+	b.WriteByte(byte(pdu.RegDelivery))
+	// Replace Flag
+	b.WriteByte(byte(pdu.ReplaceFlag))
+	// Data Coding
+	b.WriteByte(byte(pdu.DataCoding))
+	// Default Msg Id
+	b.WriteByte(byte(pdu.SmDefaultMsgId))
+	// Msg Length, set from the message
+	if len(pdu.ShortMessage) > MAX_SHORT_MESSAGE_LEN {
+		err = os.NewError("SubmitSM: Short message exceeds 254 octets")
+		return
+	}
+	pdu.SmLength = uint8(len(pdu.ShortMessage))
+	b.WriteByte(byte(pdu.SmLength))
+	// Message
+	b.WriteString(pdu.ShortMessage)
+	// Optional params
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("SubmitSM: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("SubmitSM: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUSubmitSM) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal SubmitSM PDU to bytes
+func (pdu *PDUSubmitSM) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal SubmitSM PDU from bytes
+func (pdu *PDUSubmitSM) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// SubmitSM Response PDU
+type PDUSubmitSMResp struct {
+	PDUCommon
+	MessageId	string
+}
+
+// Read SubmitSM Response PDU
+func (pdu *PDUSubmitSMResp) read(r *bufio.Reader) (err os.Error) {
+	// Read message id (null terminated string or null)
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("SubmitSM Response: Error reading message id")
+		return
+	}
+	if len(line) > 1 {
+		pdu.MessageId = string(line[0:len(line) - 1])
+	}
+	return
+}
+
+// Write SubmitSM Response PDU
+func (pdu *PDUSubmitSMResp) write(w *bufio.Writer) (err os.Error) {
+	// Body is not returned on error
+	if pdu.Header.CmdStatus != STATUS_ESME_ROK {
+		err = pdu.writePDU(w, nil)
+		if err != nil {
+			err = os.NewError("SubmitSM Response: Error writing Header")
+		}
+		return
+	}
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("SubmitSM Response: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUSubmitSMResp) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal SubmitSM Response PDU to bytes
+func (pdu *PDUSubmitSMResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal SubmitSM Response PDU from bytes
+func (pdu *PDUSubmitSMResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// SubmitMulti PDU
+type PDUSubmitMulti struct {
+	PDUCommon
+	ServiceType	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+	NumOfDests	uint8
+	DestAddrTon	SMPPTypeOfNumber
+	DestAddrNpi	SMPPNumericPlanIndicator
+	DestAddrs	[]string
+	DestLists	[]string
+	EsmClass	SMPPEsmClassESME
+	ProtocolId	uint8
+	PriorityFlag	SMPPPriority
+	SchedDelTime	string
+	ValidityPeriod	string
+	RegDelivery	SMPPDelivery
+	ReplaceFlag	uint8
+	DataCoding	SMPPDataCoding
+	SmDefaultMsgId	uint8
+	SmLength	uint8
+	ShortMessage	string
+}
+
+// Read SubmitMulti PDU
+func (pdu *PDUSubmitMulti) read(r *bufio.Reader) (err os.Error) {
+	// Read service type (null terminated string or null)
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("SubmitMulti: Error reading service type")
+		return
+	}
+	if len(line) > 1 {
+		pdu.ServiceType = string(line[0:len(line) - 1])
+	}
+	// Read source TON/NPI
+	p := make([]byte, 2)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("SubmitMulti: Error reading source TON/NPI")
+		return
+	}
+	pdu.SourceAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.SourceAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read source address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("SubmitMulti: Error reading source address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SourceAddr = string(line[0:len(line) - 1])
+	}
+	// Read number of destinations
+	c, err := r.ReadByte()
+	if err != nil {
+		err = os.NewError("SubmitMulti: Error reading number of destinations")
+		return
+	}
+	pdu.NumOfDests = uint8(c)
+	// Read destinations
+	pdu.DestAddrs = make([]string, 0, pdu.NumOfDests)
+	pdu.DestLists = make([]string, 0, pdu.NumOfDests)
+	for i := uint8(0); i < pdu.NumOfDests; i ++ {
+		// Read destination flag
+		c, err = r.ReadByte()
+		if err != nil {
+			err = os.NewError("SubmitMulti: Error reading destination flag")
+			return
+		}
+		switch c {
+			default:
+				err = os.NewError("SubmitMulti: Invalid destination flag")
+				return
+			// SME address
+			case 0x01:
+				_, err = io.ReadFull(r, p)
+				if err != nil {
+					err = os.NewError("SubmitMulti: Error reading destination TON/NPI")
+					return
+				}
+				pdu.DestAddrTon = SMPPTypeOfNumber(p[0])
+				pdu.DestAddrNpi = SMPPNumericPlanIndicator(p[1])
+				line, err = r.ReadBytes(0x00)
+				if err != nil {
+					err = os.NewError("SubmitMulti: Error reading destination address")
+					return
+				}
+				pdu.DestAddrs = append(pdu.DestAddrs, string(line[0:len(line) - 1]))
+			// Distribution list
+			case 0x02:
+				line, err = r.ReadBytes(0x00)
+				if err != nil {
+					err = os.NewError("SubmitMulti: Error reading distribution list")
+					return
+				}
+				pdu.DestLists = append(pdu.DestLists, string(line[0:len(line) - 1]))
+		}
+	}
+	// Read ESM class, protocol id and priority flag
+	p = make([]byte, 3)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("SubmitMulti: Error reading ESM class/protocol id/priority flag")
+		return
+	}
+	pdu.EsmClass     = SMPPEsmClassESME(p[0])
+	pdu.ProtocolId   = uint8(p[1])
+	pdu.PriorityFlag = SMPPPriority(p[2])
+	// Read scheduled delivery time (should be null)
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("SubmitMulti: Error reading scheduled delivery time")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SchedDelTime = string(line[0:len(line) - 1])
+	}
+	// Read validity period (should be null)
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("SubmitMulti: Error reading validity period")
+		return
+	}
+	if len(line) > 1 {
+		pdu.ValidityPeriod = string(line[0:len(line) - 1])
+	}
+	// Read registered delivery, replace flag, data coding, default msg id and msg length
+	p = make([]byte, 5)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("SubmitMulti: Error reading message options")
+		return
+	}
+	pdu.RegDelivery    = SMPPDelivery(p[0])
+	pdu.ReplaceFlag    = uint8(p[1])
+	pdu.DataCoding     = SMPPDataCoding(p[2])
+	pdu.SmDefaultMsgId = uint8(p[3])
+	pdu.SmLength       = uint8(p[4])
+	// Read message
+	if pdu.SmLength > 0 {
+		p = make([]byte, pdu.SmLength)
+		_, err = io.ReadFull(r, p)
+		if err != nil {
+			err = os.NewError("SubmitMulti: Error reading message")
+			return
+		}
+		pdu.ShortMessage = string(p)
 	}
-	pos ++ // Null terminator
+	return
+}
+
+// Write SubmitMulti PDU
+func (pdu *PDUSubmitMulti) write(w *bufio.Writer) (err os.Error) {
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy service type
+	b.WriteString(pdu.ServiceType)
+	b.WriteByte(0x00) // Null terminator
 	// Source TON
-	p[pos] = byte(pdu.SourceAddrTon)
-	pos ++
+	b.WriteByte(byte(pdu.SourceAddrTon))
 	// Source NPI
-	p[pos] = byte(pdu.SourceAddrNpi)
-	pos ++
+	b.WriteByte(byte(pdu.SourceAddrNpi))
 	// Source Address
-	if len(pdu.SourceAddr) > 0 {
-		copy(p[pos:pos + len(pdu.SourceAddr)], []byte(pdu.SourceAddr))
-		pos += len(pdu.SourceAddr)
-	}
-	pos ++ // Null terminator
-	// Number of destinations
-	p[pos] = byte(pdu.NumOfDests)
-	pos ++
+	b.WriteString(pdu.SourceAddr)
+	b.WriteByte(0x00) // Null terminator
+	// Number of destinations, set from the destinations
+	if len(pdu.DestAddrs) + len(pdu.DestLists) > 254 {
+		err = os.NewError("SubmitMulti: Destinations exceed 254")
+		return
+	}
+	pdu.NumOfDests = uint8(len(pdu.DestAddrs) + len(pdu.DestLists))
+	b.WriteByte(byte(pdu.NumOfDests))
 	// Send destination numbers
 	for _, destNum := range pdu.DestAddrs {
 		// Number indicator
-		p[pos] = byte(0x01)
-		pos ++
+		b.WriteByte(byte(0x01))
 		// Destination TON
-		p[pos] = byte(pdu.DestAddrTon)
-		pos ++
+		b.WriteByte(byte(pdu.DestAddrTon))
 		// Destination NPI
-		p[pos] = byte(pdu.DestAddrNpi)
-		pos ++
+		b.WriteByte(byte(pdu.DestAddrNpi))
 		// Copy number
-		copy(p[pos:pos + len(destNum)], []byte(destNum))
-		pos += len(destNum) + 1
+		b.WriteString(destNum)
+		b.WriteByte(0x00)
 	}
 	// Send destination lists
 	for _, destList := range pdu.DestLists {
 		// List indicator
-		p[pos] = byte(0x02)
-		pos ++
+		b.WriteByte(byte(0x02))
 		// Copy list name
-		copy(p[pos:pos + len(destList)], []byte(destList))
-		pos += len(destList) + 1
+		b.WriteString(destList)
+		b.WriteByte(0x00)
 	}
 	// ESM Class
-	p[pos] = byte(pdu.EsmClass)
-	pos ++
+	b.WriteByte(byte(pdu.EsmClass))
 	// Protocol Id
-	p[pos] = byte(pdu.ProtocolId)
-	pos ++
+	b.WriteByte(byte(pdu.ProtocolId))
 	// Priority Flag
-	p[pos] = byte(pdu.PriorityFlag)
-	pos ++
+	b.WriteByte(byte(pdu.PriorityFlag))
 	// Sheduled Delivery Time
-	if len(pdu.SchedDelTime) > 0 {
-		copy(p[pos:pos + len(pdu.SchedDelTime)], []byte(pdu.SchedDelTime))
-		pos += len(pdu.SchedDelTime)
-	}
-	pos ++ // Null terminator
+	b.WriteString(pdu.SchedDelTime)
+	b.WriteByte(0x00) // Null terminator
 	// Validity Period
-	if len(pdu.ValidityPeriod) > 0 {
-		copy(p[pos:pos + len(pdu.ValidityPeriod)], []byte(pdu.ValidityPeriod))
-		pos += len(pdu.ValidityPeriod)
-	}
-	pos ++ // Null terminator
+	b.WriteString(pdu.ValidityPeriod)
+	b.WriteByte(0x00) // Null terminator
 	// Registered Delivery
-	p[pos] = byte(pdu.RegDelivery)
-	pos ++
+	b.WriteByte(byte(pdu.RegDelivery))
 	// Replace Flag
-	p[pos] = byte(pdu.ReplaceFlag)
-	pos ++
+	b.WriteByte(byte(pdu.ReplaceFlag))
 	// Data Coding
-	p[pos] = byte(pdu.DataCoding)
-	pos ++
+	b.WriteByte(byte(pdu.DataCoding))
 	// Default Msg Id
-	p[pos] = byte(pdu.SmDefaultMsgId)
-	pos ++
-	// Msg Length
-	p[pos] = byte(pdu.SmLength)
-	pos ++
-	// Message
-	if len(pdu.ShortMessage) > 0 {
-		copy(p[pos:pos + len(pdu.ShortMessage)], []byte(pdu.ShortMessage))
-		pos += len(pdu.ShortMessage)
-	}
-	// Write to buffer
-	_, err = w.Write(p)
-	if err != nil {
-		err = os.NewError("SubmitMulti: Error writing to buffer")
+	b.WriteByte(byte(pdu.SmDefaultMsgId))
+	// Msg Length, set from the message
+	if len(pdu.ShortMessage) > MAX_SHORT_MESSAGE_LEN {
+		err = os.NewError("SubmitMulti: Short message exceeds 254 octets")
 		return
 	}
-	// Flush write buffer
-	err = w.Flush()
+	pdu.SmLength = uint8(len(pdu.ShortMessage))
+	b.WriteByte(byte(pdu.SmLength))
+	// Message
+	b.WriteString(pdu.ShortMessage)
+	// Optional params
+	err = pdu.encodeOptional(b)
 	if err != nil {
-		err = os.NewError("SubmitMulti: Error flushing write buffer")
+		err = os.NewError("SubmitMulti: Error writing optional params")
 		return
 	}
-	// Optional params
-	err = pdu.writeOptional(w)
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
 	if err != nil {
-		err = os.NewError("SubmitMulti: Error writing optional params")
+		err = os.NewError("SubmitMulti: Error writing to buffer")
 	}
-	fmt.Printf("SubmitMulti Header: %#v\n", pdu.Header)
-	fmt.Printf("SubmitMulti p: %#v\nLength p: %d\n", p, len(p))
 	return
 }
 
@@ -658,6 +1143,16 @@ func (pdu *PDUSubmitMulti) GetStruct() interface{} {
 	return *pdu
 }
 
+// Marshal SubmitMulti PDU to bytes
+func (pdu *PDUSubmitMulti) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal SubmitMulti PDU from bytes
+func (pdu *PDUSubmitMulti) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
 // SubmitMulti Response PDU
 type PDUSubmitMultiResp struct {
 	PDUCommon
@@ -691,7 +1186,7 @@ func (pdu *PDUSubmitMultiResp) read(r *bufio.Reader) (err os.Error) {
 	for i := uint8(0); i < pdu.NumUnsuccess; i ++ {
 		// Discard Ton/Npi
 		p := make([]byte, 2)
-		_, err = r.Read(p)
+		_, err = io.ReadFull(r, p)
 		if err != nil {
 			err = os.NewError("SubmitMulti Response: Error reading TON/NPI")
 			return
@@ -707,7 +1202,7 @@ func (pdu *PDUSubmitMultiResp) read(r *bufio.Reader) (err os.Error) {
 		}
 		// Read Error code
 		p = make([]byte, 4)
-		_, err = r.Read(p)
+		_, err = io.ReadFull(r, p)
 		if err != nil {
 			err = os.NewError("SubmitMulti Response: Error reading error code")
 			return
@@ -718,7 +1213,46 @@ func (pdu *PDUSubmitMultiResp) read(r *bufio.Reader) (err os.Error) {
 }
 
 // Write SubmitMulti Response PDU
-func (pdu *PDUSubmitMultiResp) write(r *bufio.Writer) (err os.Error) {
+func (pdu *PDUSubmitMultiResp) write(w *bufio.Writer) (err os.Error) {
+	// Body is not returned on error
+	if pdu.Header.CmdStatus != STATUS_ESME_ROK {
+		err = pdu.writePDU(w, nil)
+		if err != nil {
+			err = os.NewError("SubmitMulti Response: Error writing Header")
+		}
+		return
+	}
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Number of unsuccessful destinations, set from the destinations
+	if len(pdu.Unsuccess) > 254 {
+		err = os.NewError("SubmitMulti Response: Unsuccessful destinations exceed 254")
+		return
+	}
+	pdu.NumUnsuccess = uint8(len(pdu.Unsuccess))
+	b.WriteByte(byte(pdu.NumUnsuccess))
+	// Unsuccessful destinations
+	for i, dest := range pdu.Unsuccess {
+		// TON/NPI (unknown)
+		b.Write([]byte{0x00, 0x00})
+		// Copy destination
+		b.WriteString(dest)
+		b.WriteByte(0x00)
+		// Error code
+		code := uint32(0)
+		if i < len(pdu.ErrorCodes) {
+			code = pdu.ErrorCodes[i]
+		}
+		b.Write(packUint(uint64(code), 4))
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("SubmitMulti Response: Error writing to buffer")
+	}
 	return
 }
 
@@ -727,108 +1261,1632 @@ func (pdu *PDUSubmitMultiResp) GetStruct() interface{} {
 	return *pdu
 }
 
-// PDU Header
-type PDUHeader struct {
-	CmdLength	uint32
-	CmdId		SMPPCommand
-	CmdStatus	SMPPCommandStatus
-	Sequence	uint32
+// Marshal SubmitMulti Response PDU to bytes
+func (pdu *PDUSubmitMultiResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
 }
 
-// Read PDU Header
-func (hdr *PDUHeader) read(r *bufio.Reader) (err os.Error) {
-	// Read all 16 Header bytes
-	p := make([]byte, 16)
-	_, err = r.Read(p)
-	if err != nil {
-		return
-	}
-	// Convert bytes into Header vars
-	hdr.CmdLength = uint32(unpackUint(p[0:4]))
-	hdr.CmdId     = SMPPCommand(unpackUint(p[4:8]))
-	hdr.CmdStatus = SMPPCommandStatus(unpackUint(p[8:12]))
-	hdr.Sequence  = uint32(unpackUint(p[12:16]))
-	return
+// Unmarshal SubmitMulti Response PDU from bytes
+func (pdu *PDUSubmitMultiResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
 }
 
-// Write PDU Header
-func (hdr *PDUHeader) write(w *bufio.Writer) (err os.Error) {
-	// Convert Header into byte array
-	p := make([]byte, 16)
-	copy(p[0:4],   packUint(uint64(hdr.CmdLength), 4))
-	copy(p[4:8],   packUint(uint64(hdr.CmdId), 4))
-	copy(p[8:12],  packUint(uint64(hdr.CmdStatus), 4))
-	copy(p[12:16], packUint(uint64(hdr.Sequence), 4))
-	// Write Header
-	_, err = w.Write(p)
+// DeliverSM PDU
+type PDUDeliverSM struct {
+	PDUCommon
+	ServiceType	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+	DestAddrTon	SMPPTypeOfNumber
+	DestAddrNpi	SMPPNumericPlanIndicator
+	DestAddr	string
+	EsmClass	SMPPEsmClassSMSC
+	ProtocolId	uint8
+	PriorityFlag	SMPPPriority
+	SchedDelTime	string
+	ValidityPeriod	string
+	RegDelivery	SMPPDelivery
+	ReplaceFlag	uint8
+	DataCoding	SMPPDataCoding
+	SmDefaultMsgId	uint8
+	SmLength	uint8
+	ShortMessage	string
+	normaliseIds	bool	// Received on a v3.3 session, receipt ids are normalised
+}
+
+// Read DeliverSM PDU
+func (pdu *PDUDeliverSM) read(r *bufio.Reader) (err os.Error) {
+	// Read service type (null terminated string or null)
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("DeliverSM: Error reading service type")
+		return
+	}
+	if len(line) > 1 {
+		pdu.ServiceType = string(line[0:len(line) - 1])
+	}
+	// Read source TON/NPI
+	p := make([]byte, 2)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("DeliverSM: Error reading source TON/NPI")
+		return
+	}
+	pdu.SourceAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.SourceAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read source address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("DeliverSM: Error reading source address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SourceAddr = string(line[0:len(line) - 1])
+	}
+	// Read destination TON/NPI
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("DeliverSM: Error reading destination TON/NPI")
+		return
+	}
+	pdu.DestAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.DestAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read destination address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("DeliverSM: Error reading destination address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.DestAddr = string(line[0:len(line) - 1])
+	}
+	// Read ESM class, protocol id and priority flag
+	p = make([]byte, 3)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("DeliverSM: Error reading ESM class/protocol id/priority flag")
+		return
+	}
+	pdu.EsmClass     = SMPPEsmClassSMSC(p[0])
+	pdu.ProtocolId   = uint8(p[1])
+	pdu.PriorityFlag = SMPPPriority(p[2])
+	// Read scheduled delivery time (should be null)
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("DeliverSM: Error reading scheduled delivery time")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SchedDelTime = string(line[0:len(line) - 1])
+	}
+	// Read validity period (should be null)
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("DeliverSM: Error reading validity period")
+		return
+	}
+	if len(line) > 1 {
+		pdu.ValidityPeriod = string(line[0:len(line) - 1])
+	}
+	// Read registered delivery, replace flag, data coding, default msg id and msg length
+	p = make([]byte, 5)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("DeliverSM: Error reading message options")
+		return
+	}
+	pdu.RegDelivery    = SMPPDelivery(p[0])
+	pdu.ReplaceFlag    = uint8(p[1])
+	pdu.DataCoding     = SMPPDataCoding(p[2])
+	pdu.SmDefaultMsgId = uint8(p[3])
+	pdu.SmLength       = uint8(p[4])
+	// Read message
+	if pdu.SmLength > 0 {
+		p = make([]byte, pdu.SmLength)
+		_, err = io.ReadFull(r, p)
+		if err != nil {
+			err = os.NewError("DeliverSM: Error reading message")
+			return
+		}
+		pdu.ShortMessage = string(p)
+	}
+	return
+}
+
+// Write DeliverSM PDU
+func (pdu *PDUDeliverSM) write(w *bufio.Writer) (err os.Error) {
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy service type
+	b.WriteString(pdu.ServiceType)
+	b.WriteByte(0x00) // Null terminator
+	// Source TON
+	b.WriteByte(byte(pdu.SourceAddrTon))
+	// Source NPI
+	b.WriteByte(byte(pdu.SourceAddrNpi))
+	// Source Address
+	b.WriteString(pdu.SourceAddr)
+	b.WriteByte(0x00) // Null terminator
+	// Destination TON
+	b.WriteByte(byte(pdu.DestAddrTon))
+	// Destination NPI
+	b.WriteByte(byte(pdu.DestAddrNpi))
+	// Destination Address
+	b.WriteString(pdu.DestAddr)
+	b.WriteByte(0x00) // Null terminator
+	// ESM Class
+	b.WriteByte(byte(pdu.EsmClass))
+	// Protocol Id
+	b.WriteByte(byte(pdu.ProtocolId))
+	// Priority Flag
+	b.WriteByte(byte(pdu.PriorityFlag))
+	// Sheduled Delivery Time (should be null)
+	b.WriteString(pdu.SchedDelTime)
+	b.WriteByte(0x00) // Null terminator
+	// Validity Period (should be null)
+	b.WriteString(pdu.ValidityPeriod)
+	b.WriteByte(0x00) // Null terminator
+	// Registered Delivery
+	b.WriteByte(byte(pdu.RegDelivery))
+	// Replace Flag (should be null)
+	b.WriteByte(byte(pdu.ReplaceFlag))
+	// Data Coding
+	b.WriteByte(byte(pdu.DataCoding))
+	// Default Msg Id (should be null)
+	b.WriteByte(byte(pdu.SmDefaultMsgId))
+	// Msg Length, set from the message
+	if len(pdu.ShortMessage) > MAX_SHORT_MESSAGE_LEN {
+		err = os.NewError("DeliverSM: Short message exceeds 254 octets")
+		return
+	}
+	pdu.SmLength = uint8(len(pdu.ShortMessage))
+	b.WriteByte(byte(pdu.SmLength))
+	// Message
+	b.WriteString(pdu.ShortMessage)
+	// Optional params
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("DeliverSM: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("DeliverSM: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUDeliverSM) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal DeliverSM PDU to bytes
+func (pdu *PDUDeliverSM) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal DeliverSM PDU from bytes
+func (pdu *PDUDeliverSM) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// DeliverSM Response PDU
+type PDUDeliverSMResp struct {
+	PDUCommon
+	MessageId	string
+}
+
+// Read DeliverSM Response PDU
+func (pdu *PDUDeliverSMResp) read(r *bufio.Reader) (err os.Error) {
+	// Read message id (should be null)
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("DeliverSM Response: Error reading message id")
+		return
+	}
+	if len(line) > 1 {
+		pdu.MessageId = string(line[0:len(line) - 1])
+	}
+	return
+}
+
+// Write DeliverSM Response PDU
+func (pdu *PDUDeliverSMResp) write(w *bufio.Writer) (err os.Error) {
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("DeliverSM Response: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUDeliverSMResp) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal DeliverSM Response PDU to bytes
+func (pdu *PDUDeliverSMResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal DeliverSM Response PDU from bytes
+func (pdu *PDUDeliverSMResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// QuerySM PDU
+type PDUQuerySM struct {
+	PDUCommon
+	MessageId	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+}
+
+// Read QuerySM PDU
+func (pdu *PDUQuerySM) read(r *bufio.Reader) (err os.Error) {
+	// Read message id
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("QuerySM: Error reading message id")
+		return
+	}
+	if len(line) > 1 {
+		pdu.MessageId = string(line[0:len(line) - 1])
+	}
+	// Read source TON/NPI
+	p := make([]byte, 2)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("QuerySM: Error reading source TON/NPI")
+		return
+	}
+	pdu.SourceAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.SourceAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read source address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("QuerySM: Error reading source address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SourceAddr = string(line[0:len(line) - 1])
+	}
+	return
+}
+
+// Write QuerySM PDU
+func (pdu *PDUQuerySM) write(w *bufio.Writer) (err os.Error) {
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Source TON
+	b.WriteByte(byte(pdu.SourceAddrTon))
+	// Source NPI
+	b.WriteByte(byte(pdu.SourceAddrNpi))
+	// Source Address
+	b.WriteString(pdu.SourceAddr)
+	b.WriteByte(0x00) // Null terminator
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("QuerySM: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUQuerySM) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal QuerySM PDU to bytes
+func (pdu *PDUQuerySM) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal QuerySM PDU from bytes
+func (pdu *PDUQuerySM) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// QuerySM Response PDU
+type PDUQuerySMResp struct {
+	PDUCommon
+	MessageId	string
+	FinalDate	string
+	MessageState	SMPPMessageState
+	ErrorCode	uint8
+}
+
+// Read QuerySM Response PDU
+func (pdu *PDUQuerySMResp) read(r *bufio.Reader) (err os.Error) {
+	// Read message id
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("QuerySM Response: Error reading message id")
+		return
+	}
+	if len(line) > 1 {
+		pdu.MessageId = string(line[0:len(line) - 1])
+	}
+	// Read final date
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("QuerySM Response: Error reading final date")
+		return
+	}
+	if len(line) > 1 {
+		pdu.FinalDate = string(line[0:len(line) - 1])
+	}
+	// Read message state and error code
+	p := make([]byte, 2)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("QuerySM Response: Error reading message state/error code")
+		return
+	}
+	pdu.MessageState = SMPPMessageState(p[0])
+	pdu.ErrorCode    = uint8(p[1])
+	return
+}
+
+// Write QuerySM Response PDU
+func (pdu *PDUQuerySMResp) write(w *bufio.Writer) (err os.Error) {
+	// Body is not returned on error
+	if pdu.Header.CmdStatus != STATUS_ESME_ROK {
+		err = pdu.writePDU(w, nil)
+		if err != nil {
+			err = os.NewError("QuerySM Response: Error writing Header")
+		}
+		return
+	}
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Copy final date
+	b.WriteString(pdu.FinalDate)
+	b.WriteByte(0x00) // Null terminator
+	// Message state
+	b.WriteByte(byte(pdu.MessageState))
+	// Error code
+	b.WriteByte(byte(pdu.ErrorCode))
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("QuerySM Response: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUQuerySMResp) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal QuerySM Response PDU to bytes
+func (pdu *PDUQuerySMResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal QuerySM Response PDU from bytes
+func (pdu *PDUQuerySMResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// CancelSM PDU
+type PDUCancelSM struct {
+	PDUCommon
+	ServiceType	string
+	MessageId	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+	DestAddrTon	SMPPTypeOfNumber
+	DestAddrNpi	SMPPNumericPlanIndicator
+	DestAddr	string
+}
+
+// Read CancelSM PDU
+func (pdu *PDUCancelSM) read(r *bufio.Reader) (err os.Error) {
+	// Read service type
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("CancelSM: Error reading service type")
+		return
+	}
+	if len(line) > 1 {
+		pdu.ServiceType = string(line[0:len(line) - 1])
+	}
+	// Read message id
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("CancelSM: Error reading message id")
+		return
+	}
+	if len(line) > 1 {
+		pdu.MessageId = string(line[0:len(line) - 1])
+	}
+	// Read source TON/NPI
+	p := make([]byte, 2)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("CancelSM: Error reading source TON/NPI")
+		return
+	}
+	pdu.SourceAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.SourceAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read source address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("CancelSM: Error reading source address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SourceAddr = string(line[0:len(line) - 1])
+	}
+	// Read destination TON/NPI
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("CancelSM: Error reading destination TON/NPI")
+		return
+	}
+	pdu.DestAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.DestAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read destination address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("CancelSM: Error reading destination address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.DestAddr = string(line[0:len(line) - 1])
+	}
+	return
+}
+
+// Write CancelSM PDU
+func (pdu *PDUCancelSM) write(w *bufio.Writer) (err os.Error) {
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy service type
+	b.WriteString(pdu.ServiceType)
+	b.WriteByte(0x00) // Null terminator
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Source TON
+	b.WriteByte(byte(pdu.SourceAddrTon))
+	// Source NPI
+	b.WriteByte(byte(pdu.SourceAddrNpi))
+	// Source Address
+	b.WriteString(pdu.SourceAddr)
+	b.WriteByte(0x00) // Null terminator
+	// Destination TON
+	b.WriteByte(byte(pdu.DestAddrTon))
+	// Destination NPI
+	b.WriteByte(byte(pdu.DestAddrNpi))
+	// Destination Address
+	b.WriteString(pdu.DestAddr)
+	b.WriteByte(0x00) // Null terminator
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("CancelSM: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUCancelSM) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal CancelSM PDU to bytes
+func (pdu *PDUCancelSM) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal CancelSM PDU from bytes
+func (pdu *PDUCancelSM) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// CancelSM Response PDU
+type PDUCancelSMResp struct {
+	PDUCommon
+}
+
+// Read CancelSM Response PDU
+func (pdu *PDUCancelSMResp) read(r *bufio.Reader) (err os.Error) {
+	return
+}
+
+// Write CancelSM Response PDU
+func (pdu *PDUCancelSMResp) write(w *bufio.Writer) (err os.Error) {
+	// No mandatory params, any optional params are the body
+	b := new(bytes.Buffer)
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("CancelSM Response: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("CancelSM Response: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUCancelSMResp) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal CancelSM Response PDU to bytes
+func (pdu *PDUCancelSMResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal CancelSM Response PDU from bytes
+func (pdu *PDUCancelSMResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// ReplaceSM PDU
+type PDUReplaceSM struct {
+	PDUCommon
+	MessageId	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+	SchedDelTime	string
+	ValidityPeriod	string
+	RegDelivery	SMPPDelivery
+	SmDefaultMsgId	uint8
+	SmLength	uint8
+	ShortMessage	string
+}
+
+// Read ReplaceSM PDU
+func (pdu *PDUReplaceSM) read(r *bufio.Reader) (err os.Error) {
+	// Read message id
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("ReplaceSM: Error reading message id")
+		return
+	}
+	if len(line) > 1 {
+		pdu.MessageId = string(line[0:len(line) - 1])
+	}
+	// Read source TON/NPI
+	p := make([]byte, 2)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("ReplaceSM: Error reading source TON/NPI")
+		return
+	}
+	pdu.SourceAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.SourceAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read source address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("ReplaceSM: Error reading source address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SourceAddr = string(line[0:len(line) - 1])
+	}
+	// Read schedule delivery time
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("ReplaceSM: Error reading schedule delivery time")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SchedDelTime = string(line[0:len(line) - 1])
+	}
+	// Read validity period
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("ReplaceSM: Error reading validity period")
+		return
+	}
+	if len(line) > 1 {
+		pdu.ValidityPeriod = string(line[0:len(line) - 1])
+	}
+	// Read registered delivery, default message id and message length
+	p = make([]byte, 3)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("ReplaceSM: Error reading registered delivery/default message id/message length")
+		return
+	}
+	pdu.RegDelivery    = SMPPDelivery(p[0])
+	pdu.SmDefaultMsgId = uint8(p[1])
+	pdu.SmLength       = uint8(p[2])
+	// Read message
+	if pdu.SmLength > 0 {
+		p = make([]byte, pdu.SmLength)
+		_, err = io.ReadFull(r, p)
+		if err != nil {
+			err = os.NewError("ReplaceSM: Error reading message")
+			return
+		}
+		pdu.ShortMessage = string(p)
+	}
+	return
+}
+
+// Write ReplaceSM PDU
+func (pdu *PDUReplaceSM) write(w *bufio.Writer) (err os.Error) {
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Source TON
+	b.WriteByte(byte(pdu.SourceAddrTon))
+	// Source NPI
+	b.WriteByte(byte(pdu.SourceAddrNpi))
+	// Source Address
+	b.WriteString(pdu.SourceAddr)
+	b.WriteByte(0x00) // Null terminator
+	// Schedule Delivery Time
+	b.WriteString(pdu.SchedDelTime)
+	b.WriteByte(0x00) // Null terminator
+	// Validity Period
+	b.WriteString(pdu.ValidityPeriod)
+	b.WriteByte(0x00) // Null terminator
+	// Registered Delivery
+	b.WriteByte(byte(pdu.RegDelivery))
+	// Default Message Id
+	b.WriteByte(byte(pdu.SmDefaultMsgId))
+	// Message Length, set from the message
+	if len(pdu.ShortMessage) > MAX_SHORT_MESSAGE_LEN {
+		err = os.NewError("ReplaceSM: Short message exceeds 254 octets")
+		return
+	}
+	pdu.SmLength = uint8(len(pdu.ShortMessage))
+	b.WriteByte(byte(pdu.SmLength))
+	// Message
+	b.WriteString(pdu.ShortMessage)
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("ReplaceSM: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUReplaceSM) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal ReplaceSM PDU to bytes
+func (pdu *PDUReplaceSM) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal ReplaceSM PDU from bytes
+func (pdu *PDUReplaceSM) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// ReplaceSM Response PDU
+type PDUReplaceSMResp struct {
+	PDUCommon
+}
+
+// Read ReplaceSM Response PDU
+func (pdu *PDUReplaceSMResp) read(r *bufio.Reader) (err os.Error) {
+	return
+}
+
+// Write ReplaceSM Response PDU
+func (pdu *PDUReplaceSMResp) write(w *bufio.Writer) (err os.Error) {
+	// No mandatory params, any optional params are the body
+	b := new(bytes.Buffer)
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("ReplaceSM Response: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("ReplaceSM Response: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUReplaceSMResp) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal ReplaceSM Response PDU to bytes
+func (pdu *PDUReplaceSMResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal ReplaceSM Response PDU from bytes
+func (pdu *PDUReplaceSMResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// DataSM PDU
+type PDUDataSM struct {
+	PDUCommon
+	ServiceType	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+	DestAddrTon	SMPPTypeOfNumber
+	DestAddrNpi	SMPPNumericPlanIndicator
+	DestAddr	string
+	EsmClass	SMPPEsmClassESME
+	RegDelivery	SMPPDelivery
+	DataCoding	SMPPDataCoding
+}
+
+// Read DataSM PDU
+func (pdu *PDUDataSM) read(r *bufio.Reader) (err os.Error) {
+	// Read service type (null terminated string or null)
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("DataSM: Error reading service type")
+		return
+	}
+	if len(line) > 1 {
+		pdu.ServiceType = string(line[0:len(line) - 1])
+	}
+	// Read source TON/NPI
+	p := make([]byte, 2)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("DataSM: Error reading source TON/NPI")
+		return
+	}
+	pdu.SourceAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.SourceAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read source address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("DataSM: Error reading source address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SourceAddr = string(line[0:len(line) - 1])
+	}
+	// Read destination TON/NPI
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("DataSM: Error reading destination TON/NPI")
+		return
+	}
+	pdu.DestAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.DestAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read destination address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("DataSM: Error reading destination address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.DestAddr = string(line[0:len(line) - 1])
+	}
+	// Read ESM class, registered delivery and data coding
+	p = make([]byte, 3)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("DataSM: Error reading ESM class/registered delivery/data coding")
+		return
+	}
+	pdu.EsmClass    = SMPPEsmClassESME(p[0])
+	pdu.RegDelivery = SMPPDelivery(p[1])
+	pdu.DataCoding  = SMPPDataCoding(p[2])
+	return
+}
+
+// Write DataSM PDU
+func (pdu *PDUDataSM) write(w *bufio.Writer) (err os.Error) {
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy service type
+	b.WriteString(pdu.ServiceType)
+	b.WriteByte(0x00) // Null terminator
+	// Source TON
+	b.WriteByte(byte(pdu.SourceAddrTon))
+	// Source NPI
+	b.WriteByte(byte(pdu.SourceAddrNpi))
+	// Source Address
+	b.WriteString(pdu.SourceAddr)
+	b.WriteByte(0x00) // Null terminator
+	// Destination TON
+	b.WriteByte(byte(pdu.DestAddrTon))
+	// Destination NPI
+	b.WriteByte(byte(pdu.DestAddrNpi))
+	// Destination Address
+	b.WriteString(pdu.DestAddr)
+	b.WriteByte(0x00) // Null terminator
+	// ESM Class
+	b.WriteByte(byte(pdu.EsmClass))
+	// Registered Delivery
+	b.WriteByte(byte(pdu.RegDelivery))
+	// Data Coding
+	b.WriteByte(byte(pdu.DataCoding))
+	// Optional params
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("DataSM: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("DataSM: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUDataSM) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal DataSM PDU to bytes
+func (pdu *PDUDataSM) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal DataSM PDU from bytes
+func (pdu *PDUDataSM) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// DataSM Response PDU
+type PDUDataSMResp struct {
+	PDUCommon
+	MessageId	string
+}
+
+// Read DataSM Response PDU
+func (pdu *PDUDataSMResp) read(r *bufio.Reader) (err os.Error) {
+	// Read message id (null terminated string or null)
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("DataSM Response: Error reading message id")
+		return
+	}
+	if len(line) > 1 {
+		pdu.MessageId = string(line[0:len(line) - 1])
+	}
+	return
+}
+
+// Write DataSM Response PDU
+func (pdu *PDUDataSMResp) write(w *bufio.Writer) (err os.Error) {
+	// Body is not returned on error without optional params
+	if pdu.Header.CmdStatus != STATUS_ESME_ROK && len(pdu.Optional) == 0 {
+		err = pdu.writePDU(w, nil)
+		if err != nil {
+			err = os.NewError("DataSM Response: Error writing Header")
+		}
+		return
+	}
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Optional params
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("DataSM Response: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("DataSM Response: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUDataSMResp) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal DataSM Response PDU to bytes
+func (pdu *PDUDataSMResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal DataSM Response PDU from bytes
+func (pdu *PDUDataSMResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// Get the delivery failure reason, ok is false if not present
+func (pdu *PDUDataSMResp) DeliveryFailureReason() (reason uint8, ok bool) {
+	reason, ok = pdu.Optional[TAG_DELIVERY_FAILURE_REASON].(uint8)
+	return
+}
+
+// Get the network error code (network type and error code), ok is false if not present
+func (pdu *PDUDataSMResp) NetworkErrorCode() (network uint8, code uint16, ok bool) {
+	val, ok := pdu.Optional[TAG_NETWORK_ERROR_CODE].([]byte)
+	if !ok || len(val) != 3 {
+		return 0, 0, false
+	}
+	return uint8(val[0]), uint16(val[1]) << 8 | uint16(val[2]), true
+}
+
+// Get the additional status info text, null if not present
+func (pdu *PDUDataSMResp) AdditionalStatusInfoText() string {
+	text, _ := pdu.Optional[TAG_ADDITIONAL_STATUS_INFO_TEXT].(string)
+	return text
+}
+
+// Outbind PDU
+type PDUOutbind struct {
+	PDUCommon
+	SystemId	string
+	Password	string
+}
+
+// Read Outbind PDU
+func (pdu *PDUOutbind) read(r *bufio.Reader) (err os.Error) {
+	// Read system id (null terminated string or null)
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("Outbind: Error reading system id")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SystemId = string(line[0:len(line) - 1])
+	}
+	// Read password (null terminated string or null)
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("Outbind: Error reading password")
+		return
+	}
+	if len(line) > 1 {
+		pdu.Password = string(line[0:len(line) - 1])
+	}
+	return
+}
+
+// Write Outbind PDU
+func (pdu *PDUOutbind) write(w *bufio.Writer) (err os.Error) {
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy system id
+	b.WriteString(pdu.SystemId)
+	b.WriteByte(0x00) // Null terminator
+	// Copy password
+	b.WriteString(pdu.Password)
+	b.WriteByte(0x00) // Null terminator
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("Outbind: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUOutbind) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal Outbind PDU to bytes
+func (pdu *PDUOutbind) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal Outbind PDU from bytes
+func (pdu *PDUOutbind) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// GenericNack PDU
+type PDUGenericNack struct {
+	PDUCommon
+}
+
+// Read GenericNack PDU
+func (pdu *PDUGenericNack) read(r *bufio.Reader) (err os.Error) {
+	return
+}
+
+// Write GenericNack PDU
+func (pdu *PDUGenericNack) write(w *bufio.Writer) (err os.Error) {
+	// No mandatory params, any optional params are the body
+	b := new(bytes.Buffer)
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("GenericNack: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("GenericNack: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUGenericNack) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal GenericNack PDU to bytes
+func (pdu *PDUGenericNack) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal GenericNack PDU from bytes
+func (pdu *PDUGenericNack) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// BroadcastSM PDU
+type PDUBroadcastSM struct {
+	PDUCommon
+	ServiceType	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+	MessageId	string
+	PriorityFlag	SMPPPriority
+	SchedDelTime	string
+	ValidityPeriod	string
+	ReplaceFlag	uint8
+	DataCoding	SMPPDataCoding
+	SmDefaultMsgId	uint8
+}
+
+// Read BroadcastSM PDU
+func (pdu *PDUBroadcastSM) read(r *bufio.Reader) (err os.Error) {
+	// Read service type
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("BroadcastSM: Error reading service type")
+		return
+	}
+	if len(line) > 1 {
+		pdu.ServiceType = string(line[0:len(line) - 1])
+	}
+	// Read source TON/NPI
+	p := make([]byte, 2)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("BroadcastSM: Error reading source TON/NPI")
+		return
+	}
+	pdu.SourceAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.SourceAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read source address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("BroadcastSM: Error reading source address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SourceAddr = string(line[0:len(line) - 1])
+	}
+	// Read message id
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("BroadcastSM: Error reading message id")
+		return
+	}
+	if len(line) > 1 {
+		pdu.MessageId = string(line[0:len(line) - 1])
+	}
+	// Read priority flag
+	p = make([]byte, 1)
+	_, err = io.ReadFull(r, p)
 	if err != nil {
+		err = os.NewError("BroadcastSM: Error reading priority flag")
 		return
 	}
-	// Flush write buffer
-	err = w.Flush()
+	pdu.PriorityFlag = SMPPPriority(p[0])
+	// Read schedule delivery time
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("BroadcastSM: Error reading schedule delivery time")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SchedDelTime = string(line[0:len(line) - 1])
+	}
+	// Read validity period
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("BroadcastSM: Error reading validity period")
+		return
+	}
+	if len(line) > 1 {
+		pdu.ValidityPeriod = string(line[0:len(line) - 1])
+	}
+	// Read replace flag, data coding and default message id
+	p = make([]byte, 3)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("BroadcastSM: Error reading replace flag/data coding/default message id")
+		return
+	}
+	pdu.ReplaceFlag    = uint8(p[0])
+	pdu.DataCoding     = SMPPDataCoding(p[1])
+	pdu.SmDefaultMsgId = uint8(p[2])
+	return
+}
+
+// Write BroadcastSM PDU
+func (pdu *PDUBroadcastSM) write(w *bufio.Writer) (err os.Error) {
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy service type
+	b.WriteString(pdu.ServiceType)
+	b.WriteByte(0x00) // Null terminator
+	// Source TON
+	b.WriteByte(byte(pdu.SourceAddrTon))
+	// Source NPI
+	b.WriteByte(byte(pdu.SourceAddrNpi))
+	// Copy source address
+	b.WriteString(pdu.SourceAddr)
+	b.WriteByte(0x00) // Null terminator
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Priority flag
+	b.WriteByte(byte(pdu.PriorityFlag))
+	// Copy schedule delivery time
+	b.WriteString(pdu.SchedDelTime)
+	b.WriteByte(0x00) // Null terminator
+	// Copy validity period
+	b.WriteString(pdu.ValidityPeriod)
+	b.WriteByte(0x00) // Null terminator
+	// Replace flag
+	b.WriteByte(byte(pdu.ReplaceFlag))
+	// Data coding
+	b.WriteByte(byte(pdu.DataCoding))
+	// Default message id
+	b.WriteByte(byte(pdu.SmDefaultMsgId))
+	// Optional params
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("BroadcastSM: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("BroadcastSM: Error writing to buffer")
+	}
 	return
 }
 
-// Optional paramater
-type pduOptParam struct {
-	tag		uint16
-	length		uint16
-	value		interface{}
+// Get Struct
+func (pdu *PDUBroadcastSM) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal BroadcastSM PDU to bytes
+func (pdu *PDUBroadcastSM) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal BroadcastSM PDU from bytes
+func (pdu *PDUBroadcastSM) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// BroadcastSM Response PDU
+type PDUBroadcastSMResp struct {
+	PDUCommon
+	MessageId	string
 }
 
-// Read Optional param
-func (op *pduOptParam) read(r *bufio.Reader) (err os.Error) {
-	// Read first 4 descripter bytes
-	p := make([]byte, 4)
-	_, err = r.Read(p)
+// Read BroadcastSM Response PDU
+func (pdu *PDUBroadcastSMResp) read(r *bufio.Reader) (err os.Error) {
+	// Read message id
+	line, err := r.ReadBytes(0x00)
 	if err != nil {
+		err = os.NewError("BroadcastSM Response: Error reading message id")
 		return
 	}
-	op.tag    = uint16(unpackUint(p[0:2]))
-	op.length = uint16(unpackUint(p[2:4]))
-	// Read value data
-	if op.length > 0 {
-		vp := make([]byte, op.length)
-		_, err = r.Read(vp)
+	if len(line) > 1 {
+		pdu.MessageId = string(line[0:len(line) - 1])
+	}
+	return
+}
+
+// Write BroadcastSM Response PDU
+func (pdu *PDUBroadcastSMResp) write(w *bufio.Writer) (err os.Error) {
+	// Body is not returned on error
+	if pdu.Header.CmdStatus != STATUS_ESME_ROK {
+		err = pdu.writePDU(w, nil)
 		if err != nil {
-			return
+			err = os.NewError("BroadcastSM Response: Error writing Header")
 		}
-		// Determine data type of value
-		switch op.tag {
-			case TAG_ADDITIONAL_STATUS_INFO_TEXT, TAG_RECEIPTED_MESSAGE_ID, TAG_SOURCE_SUBADDRESS, TAG_DEST_SUBADDRESS, TAG_NETWORK_ERROR_CODE, TAG_MESSAGE_PAYLOAD, TAG_CALLBACK_NUM, TAG_CALLBACK_NUM_ATAG, TAG_ITS_SESSION_INFO:
-				op.value = string(vp)
-			case TAG_DEST_ADDR_SUBUNIT, TAG_SOURCE_ADDR_SUBUNIT, TAG_DEST_NETWORK_TYPE, TAG_SOURCE_NETWORK_TYPE, TAG_DEST_BEARER_TYPE, TAG_SOURCE_BEARER_TYPE, TAG_SOURCE_TELEMATICS_ID, TAG_PAYLOAD_TYPE, TAG_MS_MSG_WAIT_FACILITIES, TAG_PRIVACY_INDICATOR, TAG_USER_RESPONSE_CODE, TAG_LANGUAGE_INDICATOR, TAG_SAR_TOTAL_SEGMENTS, TAG_SAR_SEGMENT_SEQNUM, TAG_SC_INTERFACE_VERSION, TAG_DISPLAY_TIME, TAG_MS_VALIDITY, TAG_DPF_RESULT, TAG_SET_DPF, TAG_MS_AVAILABILITY_STATUS, TAG_DELIVERY_FAILURE_REASON, TAG_MORE_MESSAGES_TO_SEND, TAG_MESSAGE_STATE, TAG_CALLBACK_NUM_PRES_IND, TAG_NUMBER_OF_MESSAGES, TAG_SMS_SIGNAL, TAG_ITS_REPLY_TYPE, TAG_USSD_SERVICE_OP:
-				op.value = uint8(vp[0])
-			case TAG_DEST_TELEMATICS_ID, TAG_USER_MESSAGE_REFERENCE, TAG_SOURCE_PORT, TAG_DESTINATION_PORT, TAG_SAR_MSG_REF_NUM:
-				op.value = uint16(unpackUint(vp))
-			case TAG_QOS_TIME_TO_LIVE:
-				op.value = uint32(unpackUint(vp))
+		return
+	}
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Optional params
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("BroadcastSM Response: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("BroadcastSM Response: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUBroadcastSMResp) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal BroadcastSM Response PDU to bytes
+func (pdu *PDUBroadcastSMResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal BroadcastSM Response PDU from bytes
+func (pdu *PDUBroadcastSMResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// QueryBroadcastSM PDU
+type PDUQueryBroadcastSM struct {
+	PDUCommon
+	MessageId	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+}
+
+// Read QueryBroadcastSM PDU
+func (pdu *PDUQueryBroadcastSM) read(r *bufio.Reader) (err os.Error) {
+	// Read message id
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("QueryBroadcastSM: Error reading message id")
+		return
+	}
+	if len(line) > 1 {
+		pdu.MessageId = string(line[0:len(line) - 1])
+	}
+	// Read source TON/NPI
+	p := make([]byte, 2)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("QueryBroadcastSM: Error reading source TON/NPI")
+		return
+	}
+	pdu.SourceAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.SourceAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read source address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("QueryBroadcastSM: Error reading source address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SourceAddr = string(line[0:len(line) - 1])
+	}
+	return
+}
+
+// Write QueryBroadcastSM PDU
+func (pdu *PDUQueryBroadcastSM) write(w *bufio.Writer) (err os.Error) {
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Source TON
+	b.WriteByte(byte(pdu.SourceAddrTon))
+	// Source NPI
+	b.WriteByte(byte(pdu.SourceAddrNpi))
+	// Copy source address
+	b.WriteString(pdu.SourceAddr)
+	b.WriteByte(0x00) // Null terminator
+	// Optional params
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("QueryBroadcastSM: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("QueryBroadcastSM: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUQueryBroadcastSM) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal QueryBroadcastSM PDU to bytes
+func (pdu *PDUQueryBroadcastSM) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal QueryBroadcastSM PDU from bytes
+func (pdu *PDUQueryBroadcastSM) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// QueryBroadcastSM Response PDU
+type PDUQueryBroadcastSMResp struct {
+	PDUCommon
+	MessageId	string
+}
+
+// Read QueryBroadcastSM Response PDU
+func (pdu *PDUQueryBroadcastSMResp) read(r *bufio.Reader) (err os.Error) {
+	// Read message id
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("QueryBroadcastSM Response: Error reading message id")
+		return
+	}
+	if len(line) > 1 {
+		pdu.MessageId = string(line[0:len(line) - 1])
+	}
+	return
+}
+
+// Write QueryBroadcastSM Response PDU
+func (pdu *PDUQueryBroadcastSMResp) write(w *bufio.Writer) (err os.Error) {
+	// Body is not returned on error
+	if pdu.Header.CmdStatus != STATUS_ESME_ROK {
+		err = pdu.writePDU(w, nil)
+		if err != nil {
+			err = os.NewError("QueryBroadcastSM Response: Error writing Header")
 		}
-	} else {
-		op.value = nil
-	}
-	return
-}
-
-// Write Optional param
-func (op *pduOptParam) write(w *bufio.Writer) (err os.Error) {
-	// Create byte array
-	p := make([]byte, 4 + op.length)
-	copy(p[0:2], packUint(uint64(op.tag), 2))
-	copy(p[2:4], packUint(uint64(op.length), 2))
-	// Determine data type of value
-	v := reflect.NewValue(op.value)
-	switch t := v.(type) {
-		case *reflect.StringValue:
-			copy(p[4:op.length], []byte(op.value.(string)))
-		case *reflect.Uint8Value:
-			p[4] = byte(op.value.(uint8))
-		case *reflect.Uint16Value:
-			copy(p[4:6], packUint(uint64(op.value.(uint16)), 2))
-		case *reflect.Uint32Value:
-			copy(p[4:8], packUint(uint64(op.value.(uint32)), 4))
-	}
-	// Write to buffer
+		return
+	}
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Optional params
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("QueryBroadcastSM Response: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("QueryBroadcastSM Response: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUQueryBroadcastSMResp) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal QueryBroadcastSM Response PDU to bytes
+func (pdu *PDUQueryBroadcastSMResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal QueryBroadcastSM Response PDU from bytes
+func (pdu *PDUQueryBroadcastSMResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// CancelBroadcastSM PDU
+type PDUCancelBroadcastSM struct {
+	PDUCommon
+	ServiceType	string
+	MessageId	string
+	SourceAddrTon	SMPPTypeOfNumber
+	SourceAddrNpi	SMPPNumericPlanIndicator
+	SourceAddr	string
+}
+
+// Read CancelBroadcastSM PDU
+func (pdu *PDUCancelBroadcastSM) read(r *bufio.Reader) (err os.Error) {
+	// Read service type
+	line, err := r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("CancelBroadcastSM: Error reading service type")
+		return
+	}
+	if len(line) > 1 {
+		pdu.ServiceType = string(line[0:len(line) - 1])
+	}
+	// Read message id
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("CancelBroadcastSM: Error reading message id")
+		return
+	}
+	if len(line) > 1 {
+		pdu.MessageId = string(line[0:len(line) - 1])
+	}
+	// Read source TON/NPI
+	p := make([]byte, 2)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		err = os.NewError("CancelBroadcastSM: Error reading source TON/NPI")
+		return
+	}
+	pdu.SourceAddrTon = SMPPTypeOfNumber(p[0])
+	pdu.SourceAddrNpi = SMPPNumericPlanIndicator(p[1])
+	// Read source address
+	line, err = r.ReadBytes(0x00)
+	if err != nil {
+		err = os.NewError("CancelBroadcastSM: Error reading source address")
+		return
+	}
+	if len(line) > 1 {
+		pdu.SourceAddr = string(line[0:len(line) - 1])
+	}
+	return
+}
+
+// Write CancelBroadcastSM PDU
+func (pdu *PDUCancelBroadcastSM) write(w *bufio.Writer) (err os.Error) {
+	// Encode body
+	b := new(bytes.Buffer)
+	// Copy service type
+	b.WriteString(pdu.ServiceType)
+	b.WriteByte(0x00) // Null terminator
+	// Copy message id
+	b.WriteString(pdu.MessageId)
+	b.WriteByte(0x00) // Null terminator
+	// Source TON
+	b.WriteByte(byte(pdu.SourceAddrTon))
+	// Source NPI
+	b.WriteByte(byte(pdu.SourceAddrNpi))
+	// Copy source address
+	b.WriteString(pdu.SourceAddr)
+	b.WriteByte(0x00) // Null terminator
+	// Optional params
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("CancelBroadcastSM: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("CancelBroadcastSM: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUCancelBroadcastSM) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal CancelBroadcastSM PDU to bytes
+func (pdu *PDUCancelBroadcastSM) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal CancelBroadcastSM PDU from bytes
+func (pdu *PDUCancelBroadcastSM) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// CancelBroadcastSM Response PDU
+type PDUCancelBroadcastSMResp struct {
+	PDUCommon
+}
+
+// Read CancelBroadcastSM Response PDU
+func (pdu *PDUCancelBroadcastSMResp) read(r *bufio.Reader) (err os.Error) {
+	return
+}
+
+// Write CancelBroadcastSM Response PDU
+func (pdu *PDUCancelBroadcastSMResp) write(w *bufio.Writer) (err os.Error) {
+	// No mandatory params, any optional params are the body
+	b := new(bytes.Buffer)
+	err = pdu.encodeOptional(b)
+	if err != nil {
+		err = os.NewError("CancelBroadcastSM Response: Error writing optional params")
+		return
+	}
+	// Write PDU, the command length is set from the body
+	err = pdu.writePDU(w, b.Bytes())
+	if err != nil {
+		err = os.NewError("CancelBroadcastSM Response: Error writing to buffer")
+	}
+	return
+}
+
+// Get Struct
+func (pdu *PDUCancelBroadcastSMResp) GetStruct() interface{} {
+	return *pdu
+}
+
+// Marshal CancelBroadcastSM Response PDU to bytes
+func (pdu *PDUCancelBroadcastSMResp) MarshalBinary() ([]byte, os.Error) {
+	return marshalPDU(pdu)
+}
+
+// Unmarshal CancelBroadcastSM Response PDU from bytes
+func (pdu *PDUCancelBroadcastSMResp) UnmarshalBinary(p []byte) os.Error {
+	return unmarshalPDU(pdu, p)
+}
+
+// PDU Header
+type PDUHeader struct {
+	CmdLength	uint32
+	CmdId		SMPPCommand
+	CmdStatus	SMPPCommandStatus
+	Sequence	uint32
+}
+
+// Read PDU Header
+func (hdr *PDUHeader) read(r *bufio.Reader) (err os.Error) {
+	// Read all 16 Header bytes
+	p := make([]byte, 16)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		return
+	}
+	// Convert bytes into Header vars
+	hdr.CmdLength = uint32(unpackUint(p[0:4]))
+	hdr.CmdId     = SMPPCommand(unpackUint(p[4:8]))
+	hdr.CmdStatus = SMPPCommandStatus(unpackUint(p[8:12]))
+	hdr.Sequence  = uint32(unpackUint(p[12:16]))
+	return
+}
+
+// Write PDU Header
+func (hdr *PDUHeader) write(w *bufio.Writer) (err os.Error) {
+	// Convert Header into byte array
+	p := make([]byte, 16)
+	copy(p[0:4],   packUint(uint64(hdr.CmdLength), 4))
+	copy(p[4:8],   packUint(uint64(hdr.CmdId), 4))
+	copy(p[8:12],  packUint(uint64(hdr.CmdStatus), 4))
+	copy(p[12:16], packUint(uint64(hdr.Sequence), 4))
+	// Write Header
 	_, err = w.Write(p)
 	if err != nil {
 		return
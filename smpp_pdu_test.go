@@ -0,0 +1,256 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Optional param encoding tests, value and value bytes on the wire
+var paramTests = []struct {
+	tag	SMPPOptionalParamTag
+	val	interface{}
+	wire	[]byte
+}{
+	{TAG_DEST_ADDR_SUBUNIT, uint8(0x01), []byte{0x01}},
+	{TAG_USER_MESSAGE_REFERENCE, uint16(0x1234), []byte{0x12, 0x34}},
+	{TAG_QOS_TIME_TO_LIVE, uint32(0x01020304), []byte{0x01, 0x02, 0x03, 0x04}},
+	{TAG_RECEIPTED_MESSAGE_ID, "abc", []byte{'a', 'b', 'c', 0x00}},
+	{TAG_NETWORK_ERROR_CODE, []byte{0x03, 0x00, 0x01}, []byte{0x03, 0x00, 0x01}},
+	{TAG_MESSAGE_PAYLOAD, []byte("hello"), []byte("hello")},
+	{TAG_SOURCE_SUBADDRESS, []byte{0xa0, 0x00, 0x31}, []byte{0xa0, 0x00, 0x31}},
+	// Unknown tags are kept as raw bytes
+	{SMPPOptionalParamTag(0x1400), []byte{0x01, 0x02}, []byte{0x01, 0x02}},
+}
+
+func TestEncodeParam(t *testing.T) {
+	for _, test := range paramTests {
+		p, err := encodeParam(test.tag, test.val)
+		if err != nil {
+			t.Errorf("encodeParam(%s, %v): %s", test.tag, test.val, err)
+			continue
+		}
+		if !bytes.Equal(p, test.wire) {
+			t.Errorf("encodeParam(%s, %v) = %x, want %x", test.tag, test.val, p, test.wire)
+		}
+	}
+	// Values that can be given in other types
+	others := []struct {
+		tag	SMPPOptionalParamTag
+		val	interface{}
+		wire	[]byte
+	}{
+		{TAG_DEST_ADDR_SUBUNIT, 2, []byte{0x02}},
+		{TAG_USER_MESSAGE_REFERENCE, uint8(0x12), []byte{0x00, 0x12}},
+		{TAG_MORE_MESSAGES_TO_SEND, true, []byte{0x01}},
+		{TAG_RECEIPTED_MESSAGE_ID, []byte("abc"), []byte{'a', 'b', 'c', 0x00}},
+		{TAG_ALERT_ON_MESSAGE_DELIVERY, nil, []byte{}},
+	}
+	for _, test := range others {
+		p, err := encodeParam(test.tag, test.val)
+		if err != nil || !bytes.Equal(p, test.wire) {
+			t.Errorf("encodeParam(%s, %v) = %x, %v, want %x", test.tag, test.val, p, err, test.wire)
+		}
+	}
+}
+
+// Values are validated on encode
+func TestEncodeParamInvalid(t *testing.T) {
+	tests := []struct {
+		tag	SMPPOptionalParamTag
+		val	interface{}
+	}{
+		{TAG_DEST_ADDR_SUBUNIT, uint16(0x100)},
+		{TAG_DEST_ADDR_SUBUNIT, -1},
+		{TAG_DEST_ADDR_SUBUNIT, "1"},
+		{TAG_RECEIPTED_MESSAGE_ID, "a\x00b"},
+		{TAG_RECEIPTED_MESSAGE_ID, strings.Repeat("a", 65)},
+		{TAG_NETWORK_ERROR_CODE, "abc"},
+		{TAG_NETWORK_ERROR_CODE, []byte{0x03, 0x00}},
+		{TAG_ALERT_ON_MESSAGE_DELIVERY, []byte{0x01, 0x02}},
+	}
+	for _, test := range tests {
+		if p, err := encodeParam(test.tag, test.val); err == nil {
+			t.Errorf("encodeParam(%s, %v) = %x, expected error", test.tag, test.val, p)
+		}
+	}
+}
+
+func TestDecodeParam(t *testing.T) {
+	for _, test := range paramTests {
+		val := decodeParam(test.tag, test.wire)
+		if !reflect.DeepEqual(val, test.val) {
+			t.Errorf("decodeParam(%s, %x) = %#v, want %#v", test.tag, test.wire, val, test.val)
+		}
+	}
+	// Invalid lengths are kept as raw bytes, a missing null terminator is accepted
+	others := []struct {
+		tag	SMPPOptionalParamTag
+		wire	[]byte
+		val	interface{}
+	}{
+		{TAG_DEST_ADDR_SUBUNIT, []byte{0x01, 0x02}, []byte{0x01, 0x02}},
+		{TAG_NETWORK_ERROR_CODE, []byte{0x03}, []byte{0x03}},
+		{TAG_RECEIPTED_MESSAGE_ID, []byte("abc"), "abc"},
+		{TAG_ALERT_ON_MESSAGE_DELIVERY, []byte{}, nil},
+	}
+	for _, test := range others {
+		val := decodeParam(test.tag, test.wire)
+		if !reflect.DeepEqual(val, test.val) {
+			t.Errorf("decodeParam(%s, %x) = %#v, want %#v", test.tag, test.wire, val, test.val)
+		}
+	}
+}
+
+// Optional params are encoded in tag order
+func TestEncodeOptionalOrder(t *testing.T) {
+	pdu := new(PDUSubmitSMResp)
+	pdu.Optional = OptParams{
+		TAG_MESSAGE_PAYLOAD:		[]byte{0xff},
+		TAG_DEST_ADDR_SUBUNIT:		uint8(0x01),
+		TAG_USER_MESSAGE_REFERENCE:	uint16(0x0203),
+	}
+	want := []byte{0x00, 0x05, 0x00, 0x01, 0x01, 0x02, 0x04, 0x00, 0x02, 0x02, 0x03, 0x04, 0x24, 0x00, 0x01, 0xff}
+	for i := 0; i < 10; i ++ {
+		b := new(bytes.Buffer)
+		if err := pdu.encodeOptional(b); err != nil {
+			t.Fatalf("encodeOptional: %s", err)
+		}
+		if !bytes.Equal(b.Bytes(), want) {
+			t.Fatalf("encodeOptional = %x, want %x", b.Bytes(), want)
+		}
+	}
+}
+
+// Create an encoded PDU from a header and body
+func testWire(cmd SMPPCommand, status SMPPCommandStatus, sequence uint32, body string) []byte {
+	p := make([]byte, 16)
+	copy(p[0:4], packUint(uint64(16 + len(body)), 4))
+	copy(p[4:8], packUint(uint64(cmd), 4))
+	copy(p[8:12], packUint(uint64(status), 4))
+	copy(p[12:16], packUint(uint64(sequence), 4))
+	return append(p, body...)
+}
+
+// Encoded PDUs for marshal round trip tests, optional params ordered by tag
+func roundTripWire() [][]byte {
+	return [][]byte{
+		testWire(CMD_BIND_TRANSCEIVER, STATUS_ESME_ROK, 1, "user\x00pass\x00type\x00\x34\x01\x01" + "44*\x00"),
+		testWire(CMD_BIND_TRANSCEIVER_RESP, STATUS_ESME_ROK, 1, "smsc\x00" + "\x02\x10\x00\x01\x34"),
+		testWire(CMD_ENQUIRE_LINK, STATUS_ESME_ROK, 2, ""),
+		testWire(CMD_ENQUIRE_LINK_RESP, STATUS_ESME_ROK, 2, ""),
+		testWire(CMD_UNBIND, STATUS_ESME_ROK, 3, ""),
+		testWire(CMD_GENERIC_NACK, STATUS_ESME_RINVCMDID, 4, ""),
+		testWire(CMD_SUBMIT_SM, STATUS_ESME_ROK, 5, "CMT\x00\x05\x00Sender\x00\x01\x01" + "447700900000\x00\x40\x00\x02" + "101225120000000+\x00\x00\x01\x00\x08\x00\x04\x00h\x00i" +
+			"\x02\x04\x00\x02\x00\x07" + "\x04\x24\x00\x07payload"),
+		testWire(CMD_SUBMIT_SM_RESP, STATUS_ESME_ROK, 5, "msg-1\x00"),
+		// Fields that should be null are kept as decoded
+		testWire(CMD_DELIVER_SM, STATUS_ESME_ROK, 6, "CMT\x00\x00\x00" + "447700900000\x00\x00\x00Sender\x00\x00\x00\x02" +
+			"101225120000000+\x00" + "000001000000000R\x00\x00\x01\x03\x03\x02hi"),
+		// Optional params are kept on PDUs without a body
+		testWire(CMD_ENQUIRE_LINK, STATUS_ESME_ROK, 10, "\x00\x1d\x00\x04hey\x00"),
+		testWire(CMD_ENQUIRE_LINK, STATUS_ESME_ROK, 10, "\x14\x00\x00\x01\x01"),
+		testWire(CMD_UNBIND_RESP, STATUS_ESME_ROK, 11, "\x00\x1d\x00\x04bye\x00"),
+		testWire(CMD_GENERIC_NACK, STATUS_ESME_RINVCMDID, 12, "\x00\x1d\x00\x08unknown\x00"),
+		testWire(CMD_CANCEL_BROADCAST_SM_RESP, STATUS_ESME_ROK, 13, "\x00\x1d\x00\x04bye\x00"),
+		testWire(CMD_SUBMIT_MULTI, STATUS_ESME_ROK, 7, "\x00\x00\x00Sender\x00\x03" + "\x01\x01\x01" + "447700900001\x00" + "\x01\x01\x01" + "447700900002\x00" + "\x02list\x00" +
+			"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02hi"),
+		testWire(CMD_SUBMIT_MULTI_RESP, STATUS_ESME_ROK, 7, "msg-2\x00\x01" + "\x00\x00" + "447700900002\x00\x00\x00\x00\x0b"),
+		testWire(CMD_QUERY_SM_RESP, STATUS_ESME_ROK, 8, "msg-1\x00" + "101225120000000+\x00\x02\x00"),
+		testWire(CMD_DATA_SM, STATUS_ESME_ROK, 9, "WAP\x00\x00\x00Sender\x00\x00\x00" + "447700900000\x00\x40\x01\x04" +
+			"\x02\x0b\x00\x02\x0b\x84" + "\x04\x23\x00\x03\x03\x00\x00" + "\x04\x24\x00\x03\x06\x05\x04"),
+		// Optional params are sent with an error response
+		testWire(CMD_DATA_SM_RESP, STATUS_ESME_RDELIVERYFAILURE, 9, "\x00" + "\x00\x1d\x00\x09no route\x00" + "\x04\x25\x00\x01\x01"),
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	for _, p := range roundTripWire() {
+		pdu, err := DecodePDU(p)
+		if err != nil {
+			t.Errorf("DecodePDU %x: %s", p, err)
+			continue
+		}
+		// Unmarshal into a new PDU of the same type
+		updu := newPDU(pdu.GetHeader().CmdId)
+		err = updu.UnmarshalBinary(p)
+		if err != nil {
+			t.Errorf("%T UnmarshalBinary: %s", pdu, err)
+			continue
+		}
+		if !reflect.DeepEqual(updu, pdu) {
+			t.Errorf("%T UnmarshalBinary = %#v, want %#v", pdu, updu.GetStruct(), pdu.GetStruct())
+		}
+		// Marshal gives back the same bytes
+		p2, err := updu.MarshalBinary()
+		if err != nil || !bytes.Equal(p2, p) {
+			t.Errorf("%T MarshalBinary = %x, %v, want %x", pdu, p2, err, p)
+		}
+	}
+}
+
+// Create a PDU with the header set
+func testPDU(pdu PDU, cmd SMPPCommand, status SMPPCommandStatus, sequence uint32) PDU {
+	pdu.setHeader(&PDUHeader{CmdId: cmd, CmdStatus: status, Sequence: sequence})
+	return pdu
+}
+
+// Lengths and counts are set from the values
+func TestMarshalLengths(t *testing.T) {
+	sm := &PDUSubmitSM{DestAddr: "1", SmLength: 99, ShortMessage: "hello"}
+	testPDU(sm, CMD_SUBMIT_SM, STATUS_ESME_ROK, 1)
+	if p, err := sm.MarshalBinary(); err != nil || sm.SmLength != 5 || len(p) != int(sm.Header.CmdLength) {
+		t.Errorf("SubmitSM SmLength = %d, %v, want 5", sm.SmLength, err)
+	}
+	multi := &PDUSubmitMulti{DestAddrs: []string{"1", "2"}, DestLists: []string{"list"}}
+	testPDU(multi, CMD_SUBMIT_MULTI, STATUS_ESME_ROK, 1)
+	if _, err := multi.MarshalBinary(); err != nil || multi.NumOfDests != 3 {
+		t.Errorf("SubmitMulti NumOfDests = %d, %v, want 3", multi.NumOfDests, err)
+	}
+	resp := &PDUSubmitMultiResp{Unsuccess: []string{"1", "2"}, ErrorCodes: []uint32{1, 2}}
+	testPDU(resp, CMD_SUBMIT_MULTI_RESP, STATUS_ESME_ROK, 1)
+	if _, err := resp.MarshalBinary(); err != nil || resp.NumUnsuccess != 2 {
+		t.Errorf("SubmitMultiResp NumUnsuccess = %d, %v, want 2", resp.NumUnsuccess, err)
+	}
+	long := &PDUSubmitSM{DestAddr: "1", ShortMessage: string(make([]byte, 255))}
+	testPDU(long, CMD_SUBMIT_SM, STATUS_ESME_ROK, 1)
+	if _, err := long.MarshalBinary(); err == nil {
+		t.Errorf("SubmitSM with 255 octet message: expected error")
+	}
+}
+
+// Unmarshal resets the PDU so params from a previous use don't survive
+func TestUnmarshalReset(t *testing.T) {
+	p1 := testWire(CMD_DATA_SM_RESP, STATUS_ESME_ROK, 1, "1\x00" + "\x00\x05\x00\x01\x01")
+	p2 := testWire(CMD_DATA_SM_RESP, STATUS_ESME_ROK, 2, "2\x00")
+	pdu := new(PDUDataSMResp)
+	if err := pdu.UnmarshalBinary(p1); err != nil || len(pdu.Optional) != 1 {
+		t.Fatalf("UnmarshalBinary = %v, %v", pdu.Optional, err)
+	}
+	if err := pdu.UnmarshalBinary(p2); err != nil || pdu.MessageId != "2" || pdu.Optional != nil {
+		t.Errorf("UnmarshalBinary after reuse = %#v, %v", *pdu, err)
+	}
+	// Command id must match the type
+	if err := new(PDUDeliverSMResp).UnmarshalBinary(p2); err == nil {
+		t.Errorf("UnmarshalBinary with a different command id: expected error")
+	}
+}
+
+// Invalid optional params are returned separately from the mandatory params
+func TestDecodeOptionalError(t *testing.T) {
+	// Truncated param header after a valid param
+	p := testWire(CMD_DATA_SM_RESP, STATUS_ESME_ROK, 1, "1\x00" + "\x00\x05\x00\x01\x01" + "\x02\x04\x00")
+	dpdu, err := DecodePDU(p)
+	if _, ok := err.(*OptionalParamError); !ok {
+		t.Fatalf("DecodePDU error = %v, want OptionalParamError", err)
+	}
+	resp, ok := dpdu.(*PDUDataSMResp)
+	if !ok || resp.MessageId != "1" || resp.Optional[TAG_DEST_ADDR_SUBUNIT] != uint8(1) {
+		t.Errorf("DecodePDU = %#v, want the message id and the valid params", dpdu)
+	}
+}
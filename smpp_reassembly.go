@@ -0,0 +1,179 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"strings"
+	"time"
+)
+
+// Default time to wait for the remaining parts of a message (ns)
+const DEFAULT_REASSEMBLY_TIMEOUT = 60e9
+
+// Interval between checks for expired messages (ns)
+const REASSEMBLY_CHECK_INTERVAL = 1e9
+
+// Concatenated message key
+type concatKey struct {
+	source	string
+	dest	string
+	ref	uint16
+}
+
+// Parts of a concatenated message received so far
+type concatSet struct {
+	first	*PDUDeliverSM
+	parts	[]string
+	have	[]bool
+	count	int
+	expires	int64
+}
+
+// Set reassembly of concatenated messages on/off, incomplete messages are dropped after timeout (ns)
+func (smpp *smpp) Reassemble(enable bool, timeout int64) {
+	if timeout <= 0 {
+		timeout = DEFAULT_REASSEMBLY_TIMEOUT
+	}
+	smpp.mutex.Lock()
+	defer smpp.mutex.Unlock()
+	smpp.reassemble = enable
+	smpp.reassemblyTimeout = timeout
+	// Keep messages already in progress
+	if smpp.concat == nil {
+		smpp.concat = make(map[concatKey]*concatSet)
+	}
+	// Expire incomplete messages if already reading
+	if enable && smpp.reading {
+		smpp.startReassembly()
+	} else if !enable && smpp.reassemblyStop != nil {
+		close(smpp.reassemblyStop)
+		smpp.reassemblyStop = nil
+	}
+}
+
+// Start the expiry loop stopping any previous loop, called with the mutex held
+func (smpp *smpp) startReassembly() {
+	if smpp.reassemblyStop != nil {
+		close(smpp.reassemblyStop)
+	}
+	smpp.reassemblyStop = make(chan bool)
+	go smpp.reassemblyLoop(smpp.done, smpp.reassemblyStop)
+}
+
+// Add a message part to the reassembly buffer, returns the joined message once all parts are received
+// Returns nil if the message is incomplete or a duplicate part, messages that are not concatenated are returned as is
+// The joined message has the UDH and SAR params removed, messages over 254 octets are moved to the message payload
+// so the handler gets the whole decoded text from Text()
+func (smpp *smpp) reassembleSM(pdu *PDUDeliverSM) *PDUDeliverSM {
+	// Reassembly may be set while reading and parts are shared with the expiry loop
+	smpp.mutex.Lock()
+	defer smpp.mutex.Unlock()
+	if !smpp.reassemble {
+		return pdu
+	}
+	ref, total, seq, body, ok := concatInfo(pdu)
+	if !ok || total < 2 || seq == 0 || seq > total {
+		return pdu
+	}
+	// Find or create the set of parts, expired parts are dropped if the expiry loop hasn't yet
+	now := time.Nanoseconds()
+	key := concatKey{pdu.SourceAddr, pdu.DestAddr, ref}
+	set, ok := smpp.concat[key]
+	if !ok || len(set.parts) != int(total) || set.expires < now {
+		set = &concatSet{parts: make([]string, total), have: make([]bool, total), expires: now + smpp.reassemblyTimeout}
+		smpp.concat[key] = set
+	}
+	// Ignore duplicates
+	if set.have[seq - 1] {
+		return nil
+	}
+	set.parts[seq - 1] = body
+	set.have[seq - 1] = true
+	set.count ++
+	if seq == 1 {
+		set.first = pdu
+	}
+	if set.count < len(set.parts) {
+		return nil
+	}
+	smpp.concat[key] = nil, false
+	// Build joined message from the first part
+	joined := *set.first
+	joined.EsmClass &^= SMSC_GSM_UDHI
+	joined.Optional = make(OptParams)
+	for tag, val := range set.first.Optional {
+		switch tag {
+			case TAG_SAR_MSG_REF_NUM, TAG_SAR_TOTAL_SEGMENTS, TAG_SAR_SEGMENT_SEQNUM:
+			default:
+				joined.Optional[tag] = val
+		}
+	}
+	sm := strings.Join(set.parts, "")
+	if len(sm) > MAX_SHORT_MESSAGE_LEN {
+		joined.ShortMessage = ""
+		joined.Optional[TAG_MESSAGE_PAYLOAD] = []byte(sm)
+	} else {
+		joined.ShortMessage = sm
+	}
+	joined.SmLength = uint8(len(joined.ShortMessage))
+	return &joined
+}
+
+// Drop incomplete messages once they expire, runs until the reader stops or the loop is replaced
+func (smpp *smpp) reassemblyLoop(done, stop chan bool) {
+	ticker := time.NewTicker(REASSEMBLY_CHECK_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+			case <-done:
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+		}
+		now := time.Nanoseconds()
+		smpp.mutex.Lock()
+		for key, set := range smpp.concat {
+			if set.expires < now {
+				smpp.concat[key] = nil, false
+			}
+		}
+		smpp.mutex.Unlock()
+	}
+}
+
+// Get the concatenation reference, total parts, part number and message body from a UDH or SAR params
+func concatInfo(pdu *PDUDeliverSM) (ref uint16, total, seq uint8, body string, ok bool) {
+	// UDH concatenation
+	if pdu.EsmClass & SMSC_GSM_UDHI != 0 {
+		sm := pdu.ShortMessage
+		if len(sm) == 0 || int(sm[0]) + 1 > len(sm) {
+			return
+		}
+		udh := sm[1:int(sm[0]) + 1]
+		body = sm[int(sm[0]) + 1:]
+		// Find the concatenation information element
+		for i := 0; i + 2 <= len(udh); {
+			iei, l := udh[i], int(udh[i + 1])
+			if i + 2 + l > len(udh) {
+				break
+			}
+			ie := udh[i + 2:i + 2 + l]
+			switch {
+				case iei == UDH_IEI_CONCAT_8 && l == 3:
+					return uint16(ie[0]), ie[1], ie[2], body, true
+				case iei == UDH_IEI_CONCAT_16 && l == 4:
+					return uint16(ie[0]) << 8 | uint16(ie[1]), ie[2], ie[3], body, true
+			}
+			i += 2 + l
+		}
+		return
+	}
+	// SAR params
+	ref, ok1 := pdu.Optional[TAG_SAR_MSG_REF_NUM].(uint16)
+	total, ok2 := pdu.Optional[TAG_SAR_TOTAL_SEGMENTS].(uint8)
+	seq, ok3 := pdu.Optional[TAG_SAR_SEGMENT_SEQNUM].(uint8)
+	return ref, total, seq, pdu.ShortMessage, ok1 && ok2 && ok3
+}
@@ -0,0 +1,201 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"strings"
+	"testing"
+)
+
+// Create a message part with a UDH
+func udhPart(udh, body string) *PDUDeliverSM {
+	return &PDUDeliverSM{SourceAddr: "447700900000", DestAddr: "Sender", EsmClass: SMSC_GSM_UDHI, DataCoding: CODING_LATIN1, ShortMessage: string([]byte{byte(len(udh))}) + udh + body}
+}
+
+// Create a message part with SAR params
+func sarPart(ref uint16, total, seq uint8, body string) *PDUDeliverSM {
+	pdu := &PDUDeliverSM{SourceAddr: "447700900000", DestAddr: "Sender", DataCoding: CODING_LATIN1, ShortMessage: body}
+	pdu.Optional = OptParams{
+		TAG_SAR_MSG_REF_NUM:		ref,
+		TAG_SAR_TOTAL_SEGMENTS:		total,
+		TAG_SAR_SEGMENT_SEQNUM:		seq,
+		TAG_USER_MESSAGE_REFERENCE:	uint16(7),
+	}
+	return pdu
+}
+
+// Concatenation info tests, reference, total parts, part number and body
+var concatTests = []struct {
+	pdu	*PDUDeliverSM
+	ref	uint16
+	total	uint8
+	seq	uint8
+	body	string
+	ok	bool
+}{
+	// 8 bit reference
+	{udhPart("\x00\x03\x2a\x03\x01", "hello"), 0x2a, 3, 1, "hello", true},
+	// 16 bit reference
+	{udhPart("\x08\x04\x12\x34\x02\x02", "world"), 0x1234, 2, 2, "world", true},
+	// Other information elements are skipped
+	{udhPart("\x05\x04\x0b\x84\x23\xf0\x00\x03\x07\x02\x01", "wap"), 0x07, 2, 1, "wap", true},
+	// SAR params
+	{sarPart(0x0102, 4, 3, "sar"), 0x0102, 4, 3, "sar", true},
+	// Not concatenated
+	{&PDUDeliverSM{ShortMessage: "plain"}, 0, 0, 0, "plain", false},
+	{udhPart("\x05\x04\x0b\x84\x23\xf0", "port"), 0, 0, 0, "port", false},
+	// Information element exceeds the UDH, UDH exceeds the message
+	{udhPart("\x00\x04\x2a\x03\x01", "bad"), 0, 0, 0, "bad", false},
+	{&PDUDeliverSM{EsmClass: SMSC_GSM_UDHI, ShortMessage: "\x05\x00\x03"}, 0, 0, 0, "", false},
+}
+
+func TestConcatInfo(t *testing.T) {
+	for i, test := range concatTests {
+		ref, total, seq, body, ok := concatInfo(test.pdu)
+		if ok != test.ok {
+			t.Errorf("%d: concatInfo ok = %v, want %v", i, ok, test.ok)
+			continue
+		}
+		if ok && (ref != test.ref || total != test.total || seq != test.seq || body != test.body) {
+			t.Errorf("%d: concatInfo = %d, %d, %d, %q, want %d, %d, %d, %q", i, ref, total, seq, body, test.ref, test.total, test.seq, test.body)
+		}
+	}
+}
+
+// Create a session with reassembly on
+func testReassembly() *smpp {
+	sess := new(smpp)
+	sess.Reassemble(true, 0)
+	return sess
+}
+
+// Parts are joined in order whatever order they arrive in, duplicates are ignored
+func TestReassembleSM(t *testing.T) {
+	tests := []struct {
+		parts	[]*PDUDeliverSM
+		text	string
+	}{
+		{[]*PDUDeliverSM{udhPart("\x00\x03\x01\x03\x02", "b"), udhPart("\x00\x03\x01\x03\x03", "c"), udhPart("\x00\x03\x01\x03\x01", "a")}, "abc"},
+		{[]*PDUDeliverSM{udhPart("\x08\x04\x01\x01\x02\x01", "a"), udhPart("\x08\x04\x01\x01\x02\x01", "x"), udhPart("\x08\x04\x01\x01\x02\x02", "b")}, "ab"},
+		{[]*PDUDeliverSM{sarPart(9, 2, 2, "world"), sarPart(9, 2, 1, "hello ")}, "hello world"},
+	}
+	for i, test := range tests {
+		sess := testReassembly()
+		var joined *PDUDeliverSM
+		for j, part := range test.parts {
+			joined = sess.reassembleSM(part)
+			if j < len(test.parts) - 1 && joined != nil {
+				t.Errorf("%d: part %d returned a message before the last part", i, j)
+			}
+		}
+		if joined == nil {
+			t.Errorf("%d: no message after the last part", i)
+			continue
+		}
+		text, err := joined.Text()
+		if err != nil || text != test.text || joined.ShortMessage != test.text || joined.SmLength != uint8(len(test.text)) {
+			t.Errorf("%d: joined = %q (%d), %q, %v, want %q", i, joined.ShortMessage, joined.SmLength, text, err, test.text)
+		}
+		// UDH and SAR params are removed, other params are kept
+		if joined.EsmClass & SMSC_GSM_UDHI != 0 {
+			t.Errorf("%d: joined message has UDHI set", i)
+		}
+		if _, ok := joined.Optional[TAG_SAR_MSG_REF_NUM]; ok {
+			t.Errorf("%d: joined message has SAR params", i)
+		}
+		if len(sess.concat) != 0 {
+			t.Errorf("%d: %d messages left after joining", i, len(sess.concat))
+		}
+	}
+	// Parts from different sources are kept apart
+	sess := testReassembly()
+	other := udhPart("\x00\x03\x01\x02\x02", "y")
+	other.SourceAddr = "447700900001"
+	for _, part := range []*PDUDeliverSM{udhPart("\x00\x03\x01\x02\x01", "a"), other} {
+		if sess.reassembleSM(part) != nil {
+			t.Errorf("parts from different sources were joined")
+		}
+	}
+}
+
+// Messages that are not concatenated or with reassembly off are returned as is
+func TestReassembleSMPassThrough(t *testing.T) {
+	sess := testReassembly()
+	plain := &PDUDeliverSM{ShortMessage: "plain"}
+	if sess.reassembleSM(plain) != plain {
+		t.Errorf("plain message was not returned as is")
+	}
+	// Single part and invalid part numbers
+	for _, udh := range []string{"\x00\x03\x01\x01\x01", "\x00\x03\x01\x02\x00", "\x00\x03\x01\x02\x03"} {
+		part := udhPart(udh, "x")
+		if sess.reassembleSM(part) != part {
+			t.Errorf("part with UDH %x was not returned as is", udh)
+		}
+	}
+	sess.Reassemble(false, 0)
+	part := udhPart("\x00\x03\x01\x02\x01", "a")
+	if sess.reassembleSM(part) != part {
+		t.Errorf("part was not returned as is with reassembly off")
+	}
+}
+
+// Joined messages over 254 octets are moved to the message payload
+func TestReassembleSMPayload(t *testing.T) {
+	sess := testReassembly()
+	a, b := strings.Repeat("a", 150), strings.Repeat("b", 150)
+	sess.reassembleSM(sarPart(1, 2, 1, a))
+	joined := sess.reassembleSM(sarPart(1, 2, 2, b))
+	if joined == nil {
+		t.Fatalf("no message after the last part")
+	}
+	payload, _ := joined.Optional[TAG_MESSAGE_PAYLOAD].([]byte)
+	if joined.ShortMessage != "" || joined.SmLength != 0 || string(payload) != a + b {
+		t.Errorf("joined = short message %d octets, sm_length %d, payload %d octets, want 0, 0, 300", len(joined.ShortMessage), joined.SmLength, len(payload))
+	}
+	if ref, ok := joined.Optional[TAG_USER_MESSAGE_REFERENCE].(uint16); !ok || ref != 7 {
+		t.Errorf("joined message lost user_message_reference")
+	}
+	if text, err := joined.Text(); err != nil || text != a + b {
+		t.Errorf("Text() = %d chars, %v, want 300", len(text), err)
+	}
+	// Encodes with a valid sm_length
+	joined.setHeader(&PDUHeader{CmdId: CMD_DELIVER_SM})
+	if _, err := joined.MarshalBinary(); err != nil {
+		t.Errorf("MarshalBinary: %s", err)
+	}
+}
+
+// Incomplete messages are dropped once expired, a late part starts a new message
+func TestReassembleSMExpiry(t *testing.T) {
+	sess := testReassembly()
+	sess.reassembleSM(udhPart("\x00\x03\x01\x02\x01", "a"))
+	for _, set := range sess.concat {
+		set.expires = 0
+	}
+	if sess.reassembleSM(udhPart("\x00\x03\x01\x02\x02", "b")) != nil {
+		t.Errorf("expired part was joined")
+	}
+	if len(sess.concat) != 1 {
+		t.Errorf("%d messages in progress, want 1", len(sess.concat))
+	}
+	// Enabling again keeps messages in progress
+	sess.Reassemble(true, 0)
+	if joined := sess.reassembleSM(udhPart("\x00\x03\x01\x02\x01", "a")); joined == nil || joined.ShortMessage != "ab" {
+		t.Errorf("message in progress was dropped when reassembly was set again")
+	}
+}
+
+// Setting reassembly on a session that is already reading starts the expiry loop
+func TestReassembleReading(t *testing.T) {
+	sess, peer := testSession(t, 1)
+	defer peer.close(sess)
+	sess.Reassemble(true, 0)
+	sess.mutex.Lock()
+	running := sess.reassemblyStop != nil
+	sess.mutex.Unlock()
+	if !running {
+		t.Errorf("expiry loop not started on a reading session")
+	}
+}
@@ -0,0 +1,188 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Delivery receipt
+type DeliveryReceipt struct {
+	MessageId	string
+	State		SMPPMessageState
+	Stat		string
+	Submitted	int
+	Delivered	int
+	SubmitDate	*time.Time
+	DoneDate	*time.Time
+	ErrorCode	int
+	Text		string
+}
+
+// Receipt stat values (including common vendor variations)
+var receiptStates = map[string]SMPPMessageState{
+	"ENROUTE":		MSG_STATE_ENROUTE,
+	"DELIVRD":		MSG_STATE_DELIVERED,
+	"DELIVERED":		MSG_STATE_DELIVERED,
+	"EXPIRED":		MSG_STATE_EXPIRED,
+	"DELETED":		MSG_STATE_DELETED,
+	"UNDELIV":		MSG_STATE_UNDELIVERABLE,
+	"UNDELIVERABLE":	MSG_STATE_UNDELIVERABLE,
+	"ACCEPTD":		MSG_STATE_ACCEPTED,
+	"ACCEPTED":		MSG_STATE_ACCEPTED,
+	"UNKNOWN":		MSG_STATE_UNKNOWN,
+	"REJECTD":		MSG_STATE_REJECTED,
+	"REJECTED":		MSG_STATE_REJECTED,
+}
+
+// Receipt date layouts by length
+var receiptDateLayouts = map[int]string{
+	10:	"0601021504",
+	12:	"060102150405",
+	14:	"20060102150405",
+}
+
+// Check if the DeliverSM is a delivery receipt
+func (pdu *PDUDeliverSM) IsReceipt() bool {
+	msgType := pdu.EsmClass & 0x3c
+	return msgType == SMSC_MSG_TYPE_DELIVERY || msgType == SMSC_MSG_TYPE_DEL_ACK
+}
+
+// Get the delivery receipt, optional params are used in preference to the message text
+func (pdu *PDUDeliverSM) Receipt() (dr *DeliveryReceipt, err os.Error) {
+	if !pdu.IsReceipt() {
+		err = os.NewError("Receipt: DeliverSM is not a delivery receipt")
+		return
+	}
+	// Receipt text is normally in the short message or the message payload
+	text := pdu.ShortMessage
+	if text == "" {
+		payload, _ := pdu.Optional[TAG_MESSAGE_PAYLOAD].([]byte)
+		text = string(payload)
+	}
+	dr, err = ParseReceipt(text)
+	// Optional params
+	if id, ok := pdu.Optional[TAG_RECEIPTED_MESSAGE_ID].(string); ok {
+		if dr == nil {
+			dr, err = new(DeliveryReceipt), nil
+		}
+		dr.MessageId = id
+	}
+	if dr == nil {
+		return
+	}
+	if state, ok := pdu.Optional[TAG_MESSAGE_STATE].(uint8); ok {
+		dr.State = SMPPMessageState(state)
+	}
+	if code, ok := pdu.Optional[TAG_NETWORK_ERROR_CODE].([]byte); ok && len(code) == 3 {
+		dr.ErrorCode = int(code[1]) << 8 | int(code[2])
+	}
+	if pdu.normaliseIds {
+		dr.MessageId = NormaliseMessageId(dr.MessageId, false)
+	}
+	return
+}
+
+// Normalise a numeric message id to decimal without leading zeros, ids that aren't numeric are returned unchanged
+func NormaliseMessageId(id string, hex bool) string {
+	base := 10
+	if hex {
+		base = 16
+	}
+	n, err := strconv.Btoui64(id, base)
+	if err != nil {
+		return id
+	}
+	return strconv.Uitoa64(n)
+}
+
+// Parse delivery receipt text
+// e.g. id:IIIIIIIIII sub:SSS dlvrd:DDD submit date:YYMMDDhhmm done date:YYMMDDhhmm stat:DDDDDDD err:E text:...
+func ParseReceipt(text string) (dr *DeliveryReceipt, err os.Error) {
+	// Keys are matched case insensitive, text is always last and may contain anything
+	lower := asciiLower(text)
+	fields := text
+	dr = new(DeliveryReceipt)
+	if i := receiptKey(lower, "text"); i >= 0 {
+		dr.Text = text[i:]
+		fields = text[0:i]
+		lower = lower[0:i]
+	}
+	id := receiptValue(fields, lower, "id")
+	if id == "" {
+		err = os.NewError("Receipt: Message id not found")
+		return nil, err
+	}
+	dr.MessageId = id
+	dr.Stat = strings.ToUpper(receiptValue(fields, lower, "stat"))
+	dr.State = receiptStates[dr.Stat]
+	dr.Submitted, _ = strconv.Atoi(receiptValue(fields, lower, "sub"))
+	dr.Delivered, _ = strconv.Atoi(receiptValue(fields, lower, "dlvrd"))
+	dr.ErrorCode, _ = strconv.Atoi(receiptValue(fields, lower, "err"))
+	dr.SubmitDate = receiptDate(receiptValue(fields, lower, "submit date", "submit_date", "submitdate"))
+	dr.DoneDate = receiptDate(receiptValue(fields, lower, "done date", "done_date", "donedate"))
+	return
+}
+
+// Lower case ASCII letters only so byte offsets match the original text
+func asciiLower(s string) string {
+	p := []byte(s)
+	for i, c := range p {
+		if c >= 'A' && c <= 'Z' {
+			p[i] = c + 'a' - 'A'
+		}
+	}
+	return string(p)
+}
+
+// Find the start of the value for a key, returns -1 if not found
+func receiptKey(lower, key string) int {
+	key += ":"
+	for pos := 0; pos < len(lower); {
+		i := strings.Index(lower[pos:], key)
+		if i < 0 {
+			break
+		}
+		i += pos
+		// Key must start a word
+		if i == 0 || lower[i - 1] == ' ' {
+			return i + len(key)
+		}
+		pos = i + 1
+	}
+	return -1
+}
+
+// Get the value for the first key found, values end at the next space
+func receiptValue(text, lower string, keys ...string) string {
+	for _, key := range keys {
+		i := receiptKey(lower, key)
+		if i < 0 {
+			continue
+		}
+		val := text[i:]
+		if j := strings.Index(val, " "); j >= 0 {
+			val = val[0:j]
+		}
+		return val
+	}
+	return ""
+}
+
+// Parse a receipt date, returns nil if missing or invalid
+func receiptDate(val string) *time.Time {
+	layout, ok := receiptDateLayouts[len(val)]
+	if !ok {
+		return nil
+	}
+	t, err := time.Parse(layout, val)
+	if err != nil {
+		return nil
+	}
+	return t
+}
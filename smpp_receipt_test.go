@@ -0,0 +1,126 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"testing"
+	"time"
+)
+
+// Receipt parsing tests, dates are YYMMDDhhmm(ss) or YYYYMMDDhhmmss (0 if not expected)
+var receiptTests = []struct {
+	text		string
+	id		string
+	stat		string
+	state		SMPPMessageState
+	sub, dlvrd	int
+	submitDate	int64
+	doneDate	int64
+	errorCode	int
+	msg		string
+}{
+	{
+		"id:1234567890 sub:001 dlvrd:001 submit date:1012251200 done date:1012251201 stat:DELIVRD err:000 text:Hello world",
+		"1234567890", "DELIVRD", MSG_STATE_DELIVERED, 1, 1, 201012251200, 201012251201, 0, "Hello world",
+	},
+	// Keys are case insensitive, text may contain keys
+	{
+		"ID:abc123 SUB:1 DLVRD:0 SUBMIT DATE:101225120030 DONE DATE:101225130045 STAT:undeliv ERR:034 Text:id:x stat:DELIVRD",
+		"abc123", "UNDELIV", MSG_STATE_UNDELIVERABLE, 1, 0, 20101225120030, 20101225130045, 34, "id:x stat:DELIVRD",
+	},
+	// Vendor variations, underscores and 4 digit years
+	{
+		"id:42 submit_date:20101225120000 done_date:20101226120000 stat:EXPIRED err:5",
+		"42", "EXPIRED", MSG_STATE_EXPIRED, 0, 0, 20101225120000, 20101226120000, 5, "",
+	},
+	// Missing and invalid dates, unknown stat
+	{
+		"id:7 submit date:bad stat:FOO",
+		"7", "FOO", 0, 0, 0, 0, 0, 0, "",
+	},
+	// Key must start a word
+	{
+		"msgid:1 id:2 stat:ACCEPTD",
+		"2", "ACCEPTD", MSG_STATE_ACCEPTED, 0, 0, 0, 0, 0, "",
+	},
+}
+
+// Check a receipt date against YYYYMMDDhhmm(ss)
+func checkReceiptDate(t *testing.T, text, name string, date *time.Time, want int64) {
+	if want == 0 {
+		if date != nil {
+			t.Errorf("ParseReceipt(%q): %s = %v, want nil", text, name, date)
+		}
+		return
+	}
+	if want < 1e12 {
+		want *= 100
+	}
+	if date == nil {
+		t.Errorf("ParseReceipt(%q): %s = nil, want %d", text, name, want)
+		return
+	}
+	got := ((((date.Year * 100 + int64(date.Month)) * 100 + int64(date.Day)) * 100 + int64(date.Hour)) * 100 + int64(date.Minute)) * 100 + int64(date.Second)
+	if got != want {
+		t.Errorf("ParseReceipt(%q): %s = %d, want %d", text, name, got, want)
+	}
+}
+
+func TestParseReceipt(t *testing.T) {
+	for _, test := range receiptTests {
+		dr, err := ParseReceipt(test.text)
+		if err != nil {
+			t.Errorf("ParseReceipt(%q): %s", test.text, err)
+			continue
+		}
+		if dr.MessageId != test.id || dr.Stat != test.stat || dr.State != test.state {
+			t.Errorf("ParseReceipt(%q) = id %q stat %q state %d, want id %q stat %q state %d", test.text, dr.MessageId, dr.Stat, dr.State, test.id, test.stat, test.state)
+		}
+		if dr.Submitted != test.sub || dr.Delivered != test.dlvrd || dr.ErrorCode != test.errorCode {
+			t.Errorf("ParseReceipt(%q) = sub %d dlvrd %d err %d, want sub %d dlvrd %d err %d", test.text, dr.Submitted, dr.Delivered, dr.ErrorCode, test.sub, test.dlvrd, test.errorCode)
+		}
+		if dr.Text != test.msg {
+			t.Errorf("ParseReceipt(%q): text = %q, want %q", test.text, dr.Text, test.msg)
+		}
+		checkReceiptDate(t, test.text, "submit date", dr.SubmitDate, test.submitDate)
+		checkReceiptDate(t, test.text, "done date", dr.DoneDate, test.doneDate)
+	}
+}
+
+// A message id is required
+func TestParseReceiptInvalid(t *testing.T) {
+	for _, text := range []string{"", "stat:DELIVRD err:000", "text:id:123"} {
+		if _, err := ParseReceipt(text); err == nil {
+			t.Errorf("ParseReceipt(%q): expected error", text)
+		}
+	}
+}
+
+// Optional params are used in preference to the text
+func TestDeliverSMReceipt(t *testing.T) {
+	pdu := new(PDUDeliverSM)
+	pdu.EsmClass = SMSC_MSG_TYPE_DELIVERY
+	pdu.Optional = OptParams{
+		TAG_RECEIPTED_MESSAGE_ID:	"ABC",
+		TAG_MESSAGE_STATE:		uint8(MSG_STATE_DELIVERED),
+		TAG_NETWORK_ERROR_CODE:		[]byte{0x03, 0x01, 0x02},
+		TAG_MESSAGE_PAYLOAD:		[]byte("id:123 stat:UNDELIV err:001"),
+	}
+	if !pdu.IsReceipt() {
+		t.Fatalf("IsReceipt() = false, want true")
+	}
+	dr, err := pdu.Receipt()
+	if err != nil {
+		t.Fatalf("Receipt(): %s", err)
+	}
+	if dr.MessageId != "ABC" || dr.State != MSG_STATE_DELIVERED || dr.ErrorCode != 0x0102 || dr.Stat != "UNDELIV" {
+		t.Errorf("Receipt() = id %q state %d err %d stat %q, want id ABC state %d err %d stat UNDELIV", dr.MessageId, dr.State, dr.ErrorCode, dr.Stat, MSG_STATE_DELIVERED, 0x0102)
+	}
+	// Not a receipt
+	pdu.EsmClass = 0
+	if _, err = pdu.Receipt(); err == nil {
+		t.Errorf("Receipt(): expected error for a message that isn't a receipt")
+	}
+}
@@ -4,7 +4,48 @@
 // Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
 package smpp
 
+import (
+	"os"
+)
+
+// DeliverSM handler, returns the command status sent in the deliver_sm_resp
+// Handlers run on their own goroutine so they may send requests on the same session
+type DeliverSMHandler func(pdu *PDUDeliverSM) SMPPCommandStatus
+
 // Receiver type
 type Receiver struct {
 	smpp
 }
+
+// Receive messages, blocks until the connection is unbound or an error occurs (or closed if reconnecting)
+func (rx *Receiver) Receive(handler DeliverSMHandler) (err os.Error) {
+	return rx.receive(handler)
+}
+
+// Pass inbound messages to the handler until the connection is unbound or an error occurs (or closed if reconnecting)
+func (smpp *smpp) receive(handler DeliverSMHandler) (err os.Error) {
+	// Check connected and bound
+	if !smpp.connected || !smpp.bound {
+		err = os.NewError("Receive: A bound connection is required to receive messages")
+		return
+	}
+	// Check handler
+	if handler == nil {
+		err = os.NewError("Receive: A handler is required to receive messages")
+		return
+	}
+	// Start reading, inbound messages are passed to the handler
+	smpp.deliverSM = handler
+	err = smpp.startReader()
+	if err != nil {
+		return
+	}
+	// Wait until the session is closed if supervised, otherwise until the reader stops
+	if smpp.supervised {
+		<-smpp.closed
+		return
+	}
+	<-smpp.done
+	err = smpp.readErr
+	return
+}
@@ -0,0 +1,179 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"os"
+	"utf8"
+)
+
+// Max octets in a single SMS
+const SMS_MAX_OCTETS = 140
+
+// Get the next concatenated message reference
+func (smpp *smpp) nextMsgRef() uint16 {
+	smpp.mutex.Lock()
+	defer smpp.mutex.Unlock()
+	smpp.msgRef ++
+	return smpp.msgRef
+}
+
+// Send a message, long messages are split and sent as concatenated parts
+// Returns the message id of each part in order
+func (tx *Transmitter) SendMessage(dest, msg string, params *SubmitSMParams, optional ...OptParams) (msgIds []string, err os.Error) {
+	// Check connected and bound
+	if !tx.connected || !tx.bound {
+		err = os.NewError("SendMessage: A bound connection is required to send a message")
+		return
+	}
+	// Check destination number
+	if dest == "" {
+		err = os.NewError("SendMessage: A destination number is required and should not be null")
+		return
+	}
+	// Use defaults if no params
+	if params == nil {
+		params = NewSubmitSMParams()
+	}
+	// Validate params
+	err = params.Validate()
+	if err != nil {
+		return
+	}
+	if err = checkCString("DestAddr", dest, MAX_ADDR_LEN); err != nil {
+		return
+	}
+	// Split message, all parts use the same coding
+	coding := tx.messageCoding(msg, params.DataCoding)
+	udhLen := 0
+	switch tx.segmentation {
+		case SEGMENT_UDH8:
+			udhLen = 6
+		case SEGMENT_UDH16:
+			udhLen = 7
+	}
+	parts := splitText(msg, coding, segmentLimit(coding, 0), segmentLimit(coding, udhLen))
+	// Parts are concatenated with a UDH or SAR optional params
+	partParams := *params
+	ref := uint16(0)
+	if len(parts) > 1 {
+		if len(parts) > 255 {
+			err = &ParamError{"ShortMessage", "exceeds 255 segments"}
+			return
+		}
+		if tx.segmentation != SEGMENT_SAR {
+			partParams.EsmClass |= ESME_GSM_UDHI
+		}
+		ref = tx.nextMsgRef()
+	}
+	msgIds = make([]string, 0, len(parts))
+	for i, part := range parts {
+		// Encode part
+		var sm string
+		sm, err = encodeMessage(part, coding)
+		if err != nil {
+			return
+		}
+		partOptional := optional
+		if len(parts) > 1 {
+			total, seq := uint8(len(parts)), uint8(i + 1)
+			if tx.segmentation == SEGMENT_SAR {
+				partOptional = []OptParams{sarParams(ref, total, seq, optional...)}
+			} else {
+				sm = string(concatUDH(tx.segmentation, ref, total, seq)) + sm
+			}
+		}
+		// Submit part
+		var pdu *PDUSubmitSM
+		pdu, err = tx.buildSubmitSM(dest, sm, coding, &partParams, partOptional...)
+		if err != nil {
+			return
+		}
+		var rpdu PDU
+		rpdu, err = tx.request(pdu, CMD_SUBMIT_SM_RESP)
+		if err != nil {
+			return
+		}
+		msgIds = append(msgIds, rpdu.(*PDUSubmitSMResp).MessageId)
+	}
+	return
+}
+
+// Create a concatenation UDH
+func concatUDH(mode SMPPSegmentation, ref uint16, total, seq uint8) (udh []byte) {
+	if mode == SEGMENT_UDH16 {
+		return []byte{0x06, UDH_IEI_CONCAT_16, 0x04, byte(ref >> 8), byte(ref), total, seq}
+	}
+	return []byte{0x05, UDH_IEI_CONCAT_8, 0x03, byte(ref), total, seq}
+}
+
+// Create SAR optional params, merged with any other optional params
+func sarParams(ref uint16, total, seq uint8, optional ...OptParams) (op OptParams) {
+	op = make(OptParams)
+	if len(optional) > 0 {
+		for tag, val := range optional[0] {
+			op[tag] = val
+		}
+	}
+	op[TAG_SAR_MSG_REF_NUM]    = ref
+	op[TAG_SAR_TOTAL_SEGMENTS] = total
+	op[TAG_SAR_SEGMENT_SEQNUM] = seq
+	return
+}
+
+// Get the max units (septets, UCS-2 code units or octets) in a segment after the UDH
+func segmentLimit(coding SMPPDataCoding, udhLen int) int {
+	switch coding {
+		case CODING_DEFAULT:
+			return (SMS_MAX_OCTETS - udhLen) * 8 / 7
+		case CODING_UCS2:
+			return (SMS_MAX_OCTETS - udhLen) / 2
+	}
+	return SMS_MAX_OCTETS - udhLen
+}
+
+// Get the units required to encode a character
+func runeUnits(rune int, coding SMPPDataCoding) int {
+	switch coding {
+		case CODING_DEFAULT:
+			if _, ok := gsmExtensionRev[rune]; ok {
+				return 2
+			}
+			return 1
+		case CODING_UCS2:
+			if rune > 0xffff {
+				return 2
+			}
+			return 1
+		case CODING_LATIN1:
+			return 1
+	}
+	return utf8.RuneLen(rune)
+}
+
+// Split text into parts of at most multi units if it exceeds single units,
+// escape sequences and surrogate pairs are never split
+func splitText(text string, coding SMPPDataCoding, single, multi int) (parts []string) {
+	// Count units
+	total := 0
+	for _, rune := range text {
+		total += runeUnits(rune, coding)
+	}
+	if total <= single {
+		return []string{text}
+	}
+	// Split
+	start, n := 0, 0
+	for i, rune := range text {
+		u := runeUnits(rune, coding)
+		if n + u > multi {
+			parts = append(parts, text[start:i])
+			start, n = i, 0
+		}
+		n += u
+	}
+	parts = append(parts, text[start:])
+	return
+}
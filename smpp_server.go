@@ -4,6 +4,507 @@
 // Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
 package smpp
 
+import (
+	"os"
+	"net"
+	"sync"
+	"bufio"
+	"strconv"
+)
+
+// Time allowed for an ESME to bind after connecting, in nanoseconds
+const BIND_TIMEOUT = 30e9
+
+// Authenticator for bind requests, returns the command status for the bind response
+type Authenticator func(systemId, password, systemType, addressRange string) SMPPCommandStatus
+
+// Server handler, each method returns the command status for the response
+type ServerHandler interface {
+	// Handle SubmitSM, returns the message id
+	SubmitSM(sess *ServerSession, pdu *PDUSubmitSM) (msgId string, status SMPPCommandStatus)
+	
+	// Handle SubmitMulti, returns the message id and any unsuccessful destinations with error codes
+	SubmitMulti(sess *ServerSession, pdu *PDUSubmitMulti) (msgId string, unsuccess []string, errorCodes []uint32, status SMPPCommandStatus)
+	
+	// Handle QuerySM, returns the final date, message state and error code
+	QuerySM(sess *ServerSession, pdu *PDUQuerySM) (finalDate string, state SMPPMessageState, errorCode uint8, status SMPPCommandStatus)
+	
+	// Handle CancelSM
+	CancelSM(sess *ServerSession, pdu *PDUCancelSM) (status SMPPCommandStatus)
+	
+	// Handle ReplaceSM
+	ReplaceSM(sess *ServerSession, pdu *PDUReplaceSM) (status SMPPCommandStatus)
+	
+	// Handle DataSM, returns the message id and optional params (sent whatever the status)
+	DataSM(sess *ServerSession, pdu *PDUDataSM) (msgId string, optional OptParams, status SMPPCommandStatus)
+	
+	// Handle BroadcastSM (SMPP v5.0), returns the message id
+	BroadcastSM(sess *ServerSession, pdu *PDUBroadcastSM) (msgId string, status SMPPCommandStatus)
+	
+	// Handle QueryBroadcastSM (SMPP v5.0), returns the message state and broadcast area params
+	QueryBroadcastSM(sess *ServerSession, pdu *PDUQueryBroadcastSM) (optional OptParams, status SMPPCommandStatus)
+	
+	// Handle CancelBroadcastSM (SMPP v5.0)
+	CancelBroadcastSM(sess *ServerSession, pdu *PDUCancelBroadcastSM) (status SMPPCommandStatus)
+}
+
+// Server type
 type Server struct {
+	listener	net.Listener
+	systemId	string
+	auth		Authenticator
+	handler		ServerHandler
+	mutex		sync.Mutex
+	sessions	map[*ServerSession]bool
+}
+
+// Server session, a bound ESME connection
+type ServerSession struct {
+	smpp
+	server		*Server
+	BindType	SMPPCommand
+	SystemId	string
+	SystemType	string
+	AddressRange	string
+}
+
+// Listen for connections
+func (srv *Server) listen(host string, port int) (err os.Error) {
+	srv.listener, err = net.Listen("tcp", host + ":" + strconv.Itoa(port))
+	return
+}
+
+// Accept connections, blocks until the server is closed
+func (srv *Server) Serve() (err os.Error) {
+	for {
+		var conn net.Conn
+		conn, err = srv.listener.Accept()
+		if err != nil {
+			return
+		}
+		go srv.serveConn(conn)
+	}
+	return
+}
+
+// Close the listener and all sessions
+func (srv *Server) Close() (err os.Error) {
+	err = srv.listener.Close()
+	for _, sess := range srv.Sessions() {
+		sess.Close()
+	}
+	return
+}
+
+// Get all bound sessions
+func (srv *Server) Sessions() (sessions []*ServerSession) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	sessions = make([]*ServerSession, 0, len(srv.sessions))
+	for sess, _ := range srv.sessions {
+		sessions = append(sessions, sess)
+	}
+	return
+}
+
+// Bind and serve a connection until it's unbound or closed
+func (srv *Server) serveConn(conn net.Conn) {
+	// Create new session
+	sess := new(ServerSession)
+	sess.server    = srv
+	sess.conn      = conn
+	sess.connected = true
+	sess.reader    = bufio.NewReader(conn)
+	sess.writer    = bufio.NewWriter(conn)
+	// ESME must bind within the timeout
+	err := conn.SetReadTimeout(BIND_TIMEOUT)
+	if err != nil {
+		sess.close()
+		return
+	}
+	// Wait for a bind, enquire link is answered and other requests are rejected until bound
+	var bind *PDUBind
+	for bind == nil {
+		hdr, p, err := sess.readRaw()
+		if err != nil {
+			sess.close()
+			return
+		}
+		// Requests that can't be decoded have been nacked
+		rpdu, err := sess.decode(hdr, p)
+		if err != nil || hdr.CmdId & 0x80000000 != 0 {
+			continue
+		}
+		switch pdu := rpdu.(type) {
+			case *PDUBind:
+				bind = pdu
+			case *PDUEnquireLink:
+				sess.enquireLinkResp(hdr.Sequence)
+			default:
+				sess.genericNack(hdr.Sequence, STATUS_ESME_RINVBNDSTS)
+		}
+	}
+	hdr := bind.GetHeader()
+	// Authenticate
+	status := SMPPCommandStatus(STATUS_ESME_ROK)
+	if srv.auth != nil {
+		status = srv.auth(bind.SystemId, bind.Password, bind.SystemType, bind.AddressRange)
+	}
+	sess.version   = bind.IfVersion
+	sess.scVersion = SMPP_INTERFACE_VER_50
+	err = sess.bindResp(hdr.CmdId, hdr.Sequence, status)
+	if err == nil && status == STATUS_ESME_ROK {
+		err = conn.SetReadTimeout(0)
+	}
+	if err != nil || status != STATUS_ESME_ROK {
+		sess.close()
+		return
+	}
+	// Session is bound
+	sess.BindType     = hdr.CmdId
+	sess.SystemId     = bind.SystemId
+	sess.SystemType   = bind.SystemType
+	sess.AddressRange = bind.AddressRange
+	sess.bound        = true
+	sess.setState(STATE_BOUND)
+	// Requests are dispatched to the handler from the reader
+	sess.onRequest = func(rpdu PDU) {
+		sess.dispatch(rpdu)
+	}
+	err = sess.startReader()
+	if err != nil {
+		sess.close()
+		return
+	}
+	srv.mutex.Lock()
+	srv.sessions[sess] = true
+	srv.mutex.Unlock()
+	// Wait until the reader stops
+	<-sess.done
+	srv.mutex.Lock()
+	srv.sessions[sess] = false, false
+	srv.mutex.Unlock()
+}
+
+// Dispatch a request PDU from the reader, handlers run on their own goroutine as they may send requests
+func (sess *ServerSession) dispatch(rpdu PDU) {
+	hdr := rpdu.GetHeader()
+	switch rpdu.(type) {
+		// Reply to enquire link
+		case *PDUEnquireLink:
+			sess.enquireLinkResp(hdr.Sequence)
+		// Reply to unbind and disconnect
+		case *PDUUnbind:
+			sess.handleUnbind(hdr.Sequence)
+		// Already bound
+		case *PDUBind:
+			sess.bindResp(hdr.CmdId, hdr.Sequence, STATUS_ESME_RALYBND)
+		// Passed to the server handler
+		case *PDUSubmitSM, *PDUSubmitMulti, *PDUQuerySM, *PDUCancelSM, *PDUReplaceSM, *PDUDataSM,
+			*PDUBroadcastSM, *PDUQueryBroadcastSM, *PDUCancelBroadcastSM:
+			go sess.handle(rpdu)
+		// Not sent by an ESME
+		default:
+			sess.genericNack(hdr.Sequence, STATUS_ESME_RINVCMDID)
+	}
+}
+
+// Pass a request PDU to the server handler and send the response
+func (sess *ServerSession) handle(rpdu PDU) {
+	hdr := rpdu.GetHeader()
+	handler := sess.server.handler
+	switch pdu := rpdu.(type) {
+		// SubmitSM
+		case *PDUSubmitSM:
+			msgId, status := "", SMPPCommandStatus(STATUS_ESME_RINVBNDSTS)
+			if sess.canTransmit() {
+				msgId, status = handler.SubmitSM(sess, pdu)
+			}
+			sess.submitSMResp(hdr.Sequence, msgId, status)
+		// SubmitMulti
+		case *PDUSubmitMulti:
+			resp := new(PDUSubmitMultiResp)
+			status := SMPPCommandStatus(STATUS_ESME_RINVBNDSTS)
+			if sess.canTransmit() {
+				resp.MessageId, resp.Unsuccess, resp.ErrorCodes, status = handler.SubmitMulti(sess, pdu)
+			}
+			sess.submitMultiResp(hdr.Sequence, resp, status)
+		// QuerySM
+		case *PDUQuerySM:
+			resp := new(PDUQuerySMResp)
+			resp.MessageId = pdu.MessageId
+			status := SMPPCommandStatus(STATUS_ESME_RINVBNDSTS)
+			if sess.canTransmit() {
+				resp.FinalDate, resp.MessageState, resp.ErrorCode, status = handler.QuerySM(sess, pdu)
+			}
+			sess.querySMResp(hdr.Sequence, resp, status)
+		// CancelSM
+		case *PDUCancelSM:
+			status := SMPPCommandStatus(STATUS_ESME_RINVBNDSTS)
+			if sess.canTransmit() {
+				status = handler.CancelSM(sess, pdu)
+			}
+			sess.cancelSMResp(hdr.Sequence, status)
+		// ReplaceSM
+		case *PDUReplaceSM:
+			status := SMPPCommandStatus(STATUS_ESME_RINVBNDSTS)
+			if sess.canTransmit() {
+				status = handler.ReplaceSM(sess, pdu)
+			}
+			sess.replaceSMResp(hdr.Sequence, status)
+		// DataSM
+		case *PDUDataSM:
+			msgId, optional, status := "", OptParams(nil), SMPPCommandStatus(STATUS_ESME_RINVBNDSTS)
+			if sess.canTransmit() {
+				msgId, optional, status = handler.DataSM(sess, pdu)
+			}
+			sess.dataSMResp(hdr.Sequence, msgId, optional, status)
+		// BroadcastSM
+		case *PDUBroadcastSM:
+			msgId, status := "", SMPPCommandStatus(STATUS_ESME_RINVBNDSTS)
+			if sess.canTransmit() {
+				msgId, status = handler.BroadcastSM(sess, pdu)
+			}
+			sess.broadcastSMResp(hdr.Sequence, msgId, status)
+		// QueryBroadcastSM
+		case *PDUQueryBroadcastSM:
+			resp := new(PDUQueryBroadcastSMResp)
+			resp.MessageId = pdu.MessageId
+			status := SMPPCommandStatus(STATUS_ESME_RINVBNDSTS)
+			if sess.canTransmit() {
+				resp.Optional, status = handler.QueryBroadcastSM(sess, pdu)
+			}
+			sess.queryBroadcastSMResp(hdr.Sequence, resp, status)
+		// CancelBroadcastSM
+		case *PDUCancelBroadcastSM:
+			status := SMPPCommandStatus(STATUS_ESME_RINVBNDSTS)
+			if sess.canTransmit() {
+				status = handler.CancelBroadcastSM(sess, pdu)
+			}
+			sess.cancelBroadcastSMResp(hdr.Sequence, status)
+	}
+}
+
+// Check if the session is bound to transmit
+func (sess *ServerSession) canTransmit() bool {
+	return sess.BindType == CMD_BIND_TRANSMITTER || sess.BindType == CMD_BIND_TRANSCEIVER
+}
+
+// Check if the session is bound to receive
+func (sess *ServerSession) canReceive() bool {
+	return sess.BindType == CMD_BIND_RECEIVER || sess.BindType == CMD_BIND_TRANSCEIVER
+}
+
+// Deliver SM to the ESME
+func (sess *ServerSession) DeliverSM(source, dest, msg string, params *DeliverSMParams, optional ...OptParams) (err os.Error) {
+	// Check bound to receive
+	if !sess.bound || !sess.canReceive() {
+		err = os.NewError("DeliverSM: A session bound as a receiver is required to deliver a message")
+		return
+	}
+	// Use defaults if no params
+	if params == nil {
+		params = NewDeliverSMParams()
+	}
+	// Validate params
+	err = params.Validate()
+	if err != nil {
+		return
+	}
+	if err = checkCString("SourceAddr", source, MAX_ADDR_LEN); err != nil {
+		return
+	}
+	if err = checkCString("DestAddr", dest, MAX_ADDR_LEN); err != nil {
+		return
+	}
+	// Encode message for the data coding
+	coding := sess.messageCoding(msg, params.DataCoding)
+	sm, err := encodeMessage(msg, coding)
+	if err != nil {
+		return
+	}
+	if err = checkShortMessage("ShortMessage", sm); err != nil {
+		return
+	}
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_DELIVER_SM
+	hdr.CmdStatus = STATUS_ESME_ROK
+	// Create new PDU
+	pdu := new(PDUDeliverSM)
+	// Populate params
+	pdu.ServiceType     = params.ServiceType
+	pdu.SourceAddrTon   = params.SourceAddrTon
+	pdu.SourceAddrNpi   = params.SourceAddrNpi
+	pdu.SourceAddr      = source
+	pdu.DestAddrTon     = params.DestAddrTon
+	pdu.DestAddrNpi     = params.DestAddrNpi
+	pdu.DestAddr        = dest
+	pdu.EsmClass        = params.EsmClass
+	pdu.ProtocolId      = params.ProtocolId
+	pdu.PriorityFlag    = params.PriorityFlag
+	pdu.RegDelivery     = params.RegDelivery
+	pdu.DataCoding      = coding
+	pdu.ShortMessage    = sm
+	// Optional params
+	if len(optional) > 0 {
+		pdu.Optional = optional[0]
+	}
+	// Send PDU and get response
+	pdu.setHeader(hdr)
+	_, err = sess.request(pdu, CMD_DELIVER_SM_RESP)
+	return
+}
+
+// Close the session
+func (sess *ServerSession) Close() (err os.Error) {
+	sess.setState(STATE_CLOSED)
+	if sess.connected {
+		err = sess.close()
+	}
+	return
+}
+
+// Send Bind response
+func (sess *ServerSession) bindResp(cmd SMPPCommand, sequence uint32, status SMPPCommandStatus) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = cmd | 0x80000000
+	hdr.CmdStatus = status
+	hdr.Sequence  = sequence
+	// Create Bind response PDU
+	pdu := new(PDUBindResp)
+	// Body is not returned on error, the SMSC version is only sent to v3.4 or later ESMEs
+	if status == STATUS_ESME_ROK {
+		pdu.SystemId = sess.server.systemId
+		if sess.version >= SMPP_INTERFACE_VER_34 {
+			pdu.Optional = OptParams{TAG_SC_INTERFACE_VERSION: uint8(SMPP_INTERFACE_VER_50)}
+		}
+	}
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = sess.sendResp(pdu)
+	return
+}
+
+// Send SubmitSM response
+func (sess *ServerSession) submitSMResp(sequence uint32, msgId string, status SMPPCommandStatus) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_SUBMIT_SM_RESP
+	hdr.CmdStatus = status
+	hdr.Sequence  = sequence
+	// Create SubmitSM response PDU
+	pdu := new(PDUSubmitSMResp)
+	// Body is not returned on error
+	if status == STATUS_ESME_ROK {
+		pdu.MessageId = msgId
+	}
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = sess.sendResp(pdu)
+	return
+}
+
+// Send SubmitMulti response
+func (sess *ServerSession) submitMultiResp(sequence uint32, pdu *PDUSubmitMultiResp, status SMPPCommandStatus) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_SUBMIT_MULTI_RESP
+	hdr.CmdStatus = status
+	hdr.Sequence  = sequence
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = sess.sendResp(pdu)
+	return
+}
+
+// Send QuerySM response
+func (sess *ServerSession) querySMResp(sequence uint32, pdu *PDUQuerySMResp, status SMPPCommandStatus) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_QUERY_SM_RESP
+	hdr.CmdStatus = status
+	hdr.Sequence  = sequence
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = sess.sendResp(pdu)
+	return
+}
+
+// Send CancelSM response
+func (sess *ServerSession) cancelSMResp(sequence uint32, status SMPPCommandStatus) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_CANCEL_SM_RESP
+	hdr.CmdStatus = status
+	hdr.Sequence  = sequence
+	// Create CancelSM response PDU
+	pdu := new(PDUCancelSMResp)
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = sess.sendResp(pdu)
+	return
+}
+
+// Send ReplaceSM response
+func (sess *ServerSession) replaceSMResp(sequence uint32, status SMPPCommandStatus) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_REPLACE_SM_RESP
+	hdr.CmdStatus = status
+	hdr.Sequence  = sequence
+	// Create ReplaceSM response PDU
+	pdu := new(PDUReplaceSMResp)
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = sess.sendResp(pdu)
+	return
+}
+
+// Send BroadcastSM response
+func (sess *ServerSession) broadcastSMResp(sequence uint32, msgId string, status SMPPCommandStatus) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_BROADCAST_SM_RESP
+	hdr.CmdStatus = status
+	hdr.Sequence  = sequence
+	// Create BroadcastSM response PDU
+	pdu := new(PDUBroadcastSMResp)
+	// Body is not returned on error
+	if status == STATUS_ESME_ROK {
+		pdu.MessageId = msgId
+	}
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = sess.sendResp(pdu)
+	return
+}
+
+// Send QueryBroadcastSM response
+func (sess *ServerSession) queryBroadcastSMResp(sequence uint32, pdu *PDUQueryBroadcastSMResp, status SMPPCommandStatus) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_QUERY_BROADCAST_SM_RESP
+	hdr.CmdStatus = status
+	hdr.Sequence  = sequence
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = sess.sendResp(pdu)
+	return
+}
 
+// Send CancelBroadcastSM response
+func (sess *ServerSession) cancelBroadcastSMResp(sequence uint32, status SMPPCommandStatus) (err os.Error) {
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_CANCEL_BROADCAST_SM_RESP
+	hdr.CmdStatus = status
+	hdr.Sequence  = sequence
+	// Create CancelBroadcastSM response PDU
+	pdu := new(PDUCancelBroadcastSMResp)
+	pdu.setHeader(hdr)
+	// Send PDU
+	err = sess.sendResp(pdu)
+	return
 }
@@ -0,0 +1,173 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"os"
+	"rand"
+	"time"
+)
+
+// Connection state handler, called on each state change
+type StateHandler func(state SMPPState)
+
+// Set the state change handler
+func (smpp *smpp) StateChange(handler StateHandler) {
+	smpp.stateChange = handler
+}
+
+// Get the connection state
+func (smpp *smpp) State() SMPPState {
+	return smpp.state
+}
+
+// Set the connection state and notify the handler
+func (smpp *smpp) setState(state SMPPState) {
+	if smpp.state == state {
+		return
+	}
+	smpp.state = state
+	if smpp.stateChange != nil {
+		smpp.stateChange(state)
+	}
+}
+
+// Enable reconnecting, the connection is redialed and rebound with exponential backoff between
+// minDelay and maxDelay (nanoseconds) if lost or unbound by the SMSC. Pending requests fail when the connection is lost
+func (smpp *smpp) Reconnect(minDelay, maxDelay int64) (err os.Error) {
+	// Check connected and bound
+	if !smpp.connected || !smpp.bound {
+		err = os.NewError("Reconnect: A bound connection is required to reconnect")
+		return
+	}
+	// Outbind receivers have no host to redial
+	if smpp.host == "" {
+		err = os.NewError("Reconnect: Not supported for connections initiated by the SMSC")
+		return
+	}
+	if smpp.supervised {
+		err = os.NewError("Reconnect: Already enabled")
+		return
+	}
+	if minDelay <= 0 || maxDelay < minDelay {
+		err = os.NewError("Reconnect: Invalid delay")
+		return
+	}
+	smpp.minDelay   = minDelay
+	smpp.maxDelay   = maxDelay
+	smpp.closed     = make(chan bool)
+	smpp.supervised = true
+	// Connection loss is detected by the reader
+	err = smpp.startReader()
+	if err != nil {
+		smpp.supervised = false
+		return
+	}
+	go smpp.superviseLoop()
+	return
+}
+
+// Close the session, unbinding first if bound, stops reconnecting
+func (smpp *smpp) Close() (err os.Error) {
+	smpp.setClosing()
+	if smpp.bound {
+		smpp.setState(STATE_UNBINDING)
+		// PDU header
+		hdr := new(PDUHeader)
+		hdr.CmdId     = CMD_UNBIND
+		hdr.CmdStatus = STATUS_ESME_ROK
+		// Create unbind PDU
+		pdu := new(PDUUnbind)
+		pdu.setHeader(hdr)
+		// Unbind errors are ignored as the connection is closed anyway
+		smpp.request(pdu, CMD_UNBIND_RESP)
+	}
+	smpp.setState(STATE_CLOSED)
+	if smpp.connected {
+		err = smpp.close()
+	}
+	return
+}
+
+// Mark the session as closed locally so it isn't reconnected
+func (smpp *smpp) setClosing() {
+	smpp.mutex.Lock()
+	defer smpp.mutex.Unlock()
+	smpp.closing = true
+}
+
+// Check if the session was closed or unbound locally
+func (smpp *smpp) isClosing() bool {
+	smpp.mutex.Lock()
+	defer smpp.mutex.Unlock()
+	return smpp.closing
+}
+
+// Wait for the reader to stop then redial and rebind, until closed or unbound locally
+func (smpp *smpp) superviseLoop() {
+	defer close(smpp.closed)
+	for {
+		// Wait for the reader to stop
+		smpp.mutex.Lock()
+		done := smpp.done
+		smpp.mutex.Unlock()
+		<-done
+		// Closed or unbound locally, an unbind from the SMSC is reconnected
+		if smpp.isClosing() {
+			smpp.setState(STATE_CLOSED)
+			return
+		}
+		// Redial with exponential backoff and jitter
+		delay := smpp.minDelay
+		for {
+			err := smpp.redial()
+			if err == nil {
+				break
+			}
+			// Closed while dialing or waiting
+			if smpp.isClosing() {
+				smpp.setState(STATE_CLOSED)
+				return
+			}
+			time.Sleep(delay + rand.Int63n(delay / 2 + 1))
+			delay *= 2
+			if delay > smpp.maxDelay {
+				delay = smpp.maxDelay
+			}
+		}
+	}
+}
+
+// Redial and rebind using the original host, port and bind params then restart the reader
+func (smpp *smpp) redial() (err os.Error) {
+	// Make sure the old connection is closed
+	if smpp.connected {
+		smpp.close()
+	}
+	if smpp.isClosing() {
+		err = os.NewError("Reconnect: Session closed")
+		return
+	}
+	err = smpp.connect(smpp.host, smpp.port)
+	if err != nil {
+		return
+	}
+	err = smpp.bind(smpp.bindCmd, smpp.bindRespCmd, smpp.bindParams)
+	if err != nil {
+		smpp.close()
+		return
+	}
+	// Closed while dialing
+	if smpp.isClosing() {
+		smpp.close()
+		err = os.NewError("Reconnect: Session closed")
+		return
+	}
+	err = smpp.startReader()
+	if err != nil {
+		smpp.close()
+	}
+	return
+}
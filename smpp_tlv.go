@@ -0,0 +1,304 @@
+// GoSMPP - An SMPP library for Go
+// Copyright 2010 Phil Bayfield
+// This software is licensed under a Creative Commons Attribution-Share Alike 2.0 UK: England & Wales License
+// Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
+package smpp
+
+import (
+	"os"
+	"fmt"
+	"sort"
+	"sync"
+	"strings"
+)
+
+// Optional param definition, lengths are the value length on the wire (MaxLen 0 for no limit)
+type ParamDef struct {
+	Name	string
+	Type	SMPPParamType
+	MinLen	int
+	MaxLen	int
+}
+
+// Optional param definitions by tag
+var paramDefs = map[SMPPOptionalParamTag]ParamDef{
+	TAG_DEST_ADDR_SUBUNIT:		ParamDef{"dest_addr_subunit", TLV_INT1, 1, 1},
+	TAG_DEST_NETWORK_TYPE:		ParamDef{"dest_network_type", TLV_INT1, 1, 1},
+	TAG_DEST_BEARER_TYPE:		ParamDef{"dest_bearer_type", TLV_INT1, 1, 1},
+	TAG_DEST_TELEMATICS_ID:		ParamDef{"dest_telematics_id", TLV_INT2, 2, 2},
+	TAG_SOURCE_ADDR_SUBUNIT:	ParamDef{"source_addr_subunit", TLV_INT1, 1, 1},
+	TAG_SOURCE_NETWORK_TYPE:	ParamDef{"source_network_type", TLV_INT1, 1, 1},
+	TAG_SOURCE_BEARER_TYPE:		ParamDef{"source_bearer_type", TLV_INT1, 1, 1},
+	TAG_SOURCE_TELEMATICS_ID:	ParamDef{"source_telematics_id", TLV_INT1, 1, 1},
+	TAG_QOS_TIME_TO_LIVE:		ParamDef{"qos_time_to_live", TLV_INT4, 4, 4},
+	TAG_PAYLOAD_TYPE:		ParamDef{"payload_type", TLV_INT1, 1, 1},
+	TAG_ADDITIONAL_STATUS_INFO_TEXT:	ParamDef{"additional_status_info_text", TLV_COCTET, 1, 256},
+	TAG_RECEIPTED_MESSAGE_ID:	ParamDef{"receipted_message_id", TLV_COCTET, 1, 65},
+	TAG_MS_MSG_WAIT_FACILITIES:	ParamDef{"ms_msg_wait_facilities", TLV_INT1, 1, 1},
+	TAG_PRIVACY_INDICATOR:		ParamDef{"privacy_indicator", TLV_INT1, 1, 1},
+	TAG_SOURCE_SUBADDRESS:		ParamDef{"source_subaddress", TLV_OCTET, 2, 23},
+	TAG_DEST_SUBADDRESS:		ParamDef{"dest_subaddress", TLV_OCTET, 2, 23},
+	TAG_USER_MESSAGE_REFERENCE:	ParamDef{"user_message_reference", TLV_INT2, 2, 2},
+	TAG_USER_RESPONSE_CODE:		ParamDef{"user_response_code", TLV_INT1, 1, 1},
+	TAG_SOURCE_PORT:		ParamDef{"source_port", TLV_INT2, 2, 2},
+	TAG_DESTINATION_PORT:		ParamDef{"destination_port", TLV_INT2, 2, 2},
+	TAG_SAR_MSG_REF_NUM:		ParamDef{"sar_msg_ref_num", TLV_INT2, 2, 2},
+	TAG_LANGUAGE_INDICATOR:		ParamDef{"language_indicator", TLV_INT1, 1, 1},
+	TAG_SAR_TOTAL_SEGMENTS:		ParamDef{"sar_total_segments", TLV_INT1, 1, 1},
+	TAG_SAR_SEGMENT_SEQNUM:		ParamDef{"sar_segment_seqnum", TLV_INT1, 1, 1},
+	TAG_SC_INTERFACE_VERSION:	ParamDef{"sc_interface_version", TLV_INT1, 1, 1},
+	TAG_CALLBACK_NUM_PRES_IND:	ParamDef{"callback_num_pres_ind", TLV_INT1, 1, 1},
+	TAG_CALLBACK_NUM_ATAG:		ParamDef{"callback_num_atag", TLV_OCTET, 0, 65},
+	TAG_NUMBER_OF_MESSAGES:		ParamDef{"number_of_messages", TLV_INT1, 1, 1},
+	TAG_CALLBACK_NUM:		ParamDef{"callback_num", TLV_OCTET, 4, 19},
+	TAG_DPF_RESULT:			ParamDef{"dpf_result", TLV_INT1, 1, 1},
+	TAG_SET_DPF:			ParamDef{"set_dpf", TLV_INT1, 1, 1},
+	TAG_MS_AVAILABILITY_STATUS:	ParamDef{"ms_availability_status", TLV_INT1, 1, 1},
+	TAG_NETWORK_ERROR_CODE:		ParamDef{"network_error_code", TLV_OCTET, 3, 3},
+	TAG_MESSAGE_PAYLOAD:		ParamDef{"message_payload", TLV_OCTET, 0, 0xffff},
+	TAG_DELIVERY_FAILURE_REASON:	ParamDef{"delivery_failure_reason", TLV_INT1, 1, 1},
+	TAG_MORE_MESSAGES_TO_SEND:	ParamDef{"more_messages_to_send", TLV_INT1, 1, 1},
+	TAG_MESSAGE_STATE:		ParamDef{"message_state", TLV_INT1, 1, 1},
+	TAG_CONGESTION_STATE:		ParamDef{"congestion_state", TLV_INT1, 1, 1},
+	TAG_USSD_SERVICE_OP:		ParamDef{"ussd_service_op", TLV_INT1, 1, 1},
+	TAG_BROADCAST_CHANNEL_INDICATOR:	ParamDef{"broadcast_channel_indicator", TLV_INT1, 1, 1},
+	TAG_BROADCAST_CONTENT_TYPE:	ParamDef{"broadcast_content_type", TLV_OCTET, 3, 3},
+	TAG_BROADCAST_CONTENT_TYPE_INFO:	ParamDef{"broadcast_content_type_info", TLV_OCTET, 0, 255},
+	TAG_BROADCAST_MESSAGE_CLASS:	ParamDef{"broadcast_message_class", TLV_INT1, 1, 1},
+	TAG_BROADCAST_REP_NUM:		ParamDef{"broadcast_rep_num", TLV_INT2, 2, 2},
+	TAG_BROADCAST_FREQUENCY_INTERVAL:	ParamDef{"broadcast_frequency_interval", TLV_OCTET, 3, 3},
+	TAG_BROADCAST_AREA_IDENTIFIER:	ParamDef{"broadcast_area_identifier", TLV_OCTET, 1, 100},
+	TAG_BROADCAST_ERROR_STATUS:	ParamDef{"broadcast_error_status", TLV_INT4, 4, 4},
+	TAG_BROADCAST_AREA_SUCCESS:	ParamDef{"broadcast_area_success", TLV_INT1, 1, 1},
+	TAG_BROADCAST_END_TIME:		ParamDef{"broadcast_end_time", TLV_COCTET, 17, 17},
+	TAG_BROADCAST_SERVICE_GROUP:	ParamDef{"broadcast_service_group", TLV_OCTET, 0, 255},
+	TAG_BILLING_IDENTIFICATION:	ParamDef{"billing_identification", TLV_OCTET, 0, 1024},
+	TAG_SOURCE_NETWORK_ID:		ParamDef{"source_network_id", TLV_COCTET, 1, 65},
+	TAG_DEST_NETWORK_ID:		ParamDef{"dest_network_id", TLV_COCTET, 1, 65},
+	TAG_SOURCE_NODE_ID:		ParamDef{"source_node_id", TLV_OCTET, 6, 6},
+	TAG_DEST_NODE_ID:		ParamDef{"dest_node_id", TLV_OCTET, 6, 6},
+	TAG_DEST_ADDR_NP_RESOLUTION:	ParamDef{"dest_addr_np_resolution", TLV_INT1, 1, 1},
+	TAG_DEST_ADDR_NP_INFORMATION:	ParamDef{"dest_addr_np_information", TLV_OCTET, 10, 10},
+	TAG_DEST_ADDR_NP_COUNTRY:	ParamDef{"dest_addr_np_country", TLV_OCTET, 5, 5},
+	TAG_DISPLAY_TIME:		ParamDef{"display_time", TLV_INT1, 1, 1},
+	TAG_SMS_SIGNAL:			ParamDef{"sms_signal", TLV_INT2, 2, 2},
+	TAG_MS_VALIDITY:		ParamDef{"ms_validity", TLV_INT1, 1, 1},
+	TAG_ALERT_ON_MESSAGE_DELIVERY:	ParamDef{"alert_on_message_delivery", TLV_OCTET, 0, 1}, // No value in v3.4, 1 octet in v5.0
+	TAG_ITS_REPLY_TYPE:		ParamDef{"its_reply_type", TLV_INT1, 1, 1},
+	TAG_ITS_SESSION_INFO:		ParamDef{"its_session_info", TLV_OCTET, 2, 2},
+}
+
+// Definitions are shared by all sessions
+var paramMutex sync.RWMutex
+
+// Register an optional param definition, replaces any existing definition for the tag
+func RegisterParam(tag SMPPOptionalParamTag, def ParamDef) (err os.Error) {
+	if def.Name == "" {
+		return &ParamError{"Name", "Required"}
+	}
+	// Integer lengths are fixed by the type
+	switch def.Type {
+		case TLV_INT1:
+			def.MinLen, def.MaxLen = 1, 1
+		case TLV_INT2:
+			def.MinLen, def.MaxLen = 2, 2
+		case TLV_INT4:
+			def.MinLen, def.MaxLen = 4, 4
+		case TLV_COCTET, TLV_OCTET:
+			if def.MinLen < 0 || def.MaxLen < 0 || def.MaxLen > 0xffff || (def.MaxLen > 0 && def.MinLen > def.MaxLen) {
+				return &ParamError{"MaxLen", "Invalid length constraints"}
+			}
+		default:
+			return &ParamError{"Type", "Unknown type"}
+	}
+	paramMutex.Lock()
+	defer paramMutex.Unlock()
+	paramDefs[tag] = def
+	return
+}
+
+// Get the optional param definition for a tag
+func LookupParam(tag SMPPOptionalParamTag) (def ParamDef, ok bool) {
+	paramMutex.RLock()
+	defer paramMutex.RUnlock()
+	def, ok = paramDefs[tag]
+	return
+}
+
+// Get the optional param name
+func (tag SMPPOptionalParamTag) String() string {
+	if def, ok := LookupParam(tag); ok {
+		return def.Name
+	}
+	return fmt.Sprintf("0x%04x", uint16(tag))
+}
+
+// Tags sorted for printing and encoding
+type paramTags []SMPPOptionalParamTag
+
+func (t paramTags) Len() int		{ return len(t) }
+func (t paramTags) Less(i, j int) bool	{ return t[i] < t[j] }
+func (t paramTags) Swap(i, j int)	{ t[i], t[j] = t[j], t[i] }
+
+// Get the tags in order
+func (op OptParams) tags() paramTags {
+	tags := make(paramTags, len(op))
+	i := 0
+	for tag := range op {
+		tags[i] = tag
+		i ++
+	}
+	sort.Sort(tags)
+	return tags
+}
+
+// Get the optional params as name: value pairs ordered by tag
+func (op OptParams) String() string {
+	tags := op.tags()
+	s := make([]string, len(tags))
+	for i, tag := range tags {
+		switch val := op[tag].(type) {
+			case []byte:
+				s[i] = fmt.Sprintf("%s: %x", tag.String(), val)
+			case string:
+				s[i] = fmt.Sprintf("%s: %q", tag.String(), val)
+			default:
+				s[i] = fmt.Sprintf("%s: %v", tag.String(), val)
+		}
+	}
+	return "{" + strings.Join(s, ", ") + "}"
+}
+
+// Check the value length against the definition
+func (def ParamDef) checkLen(l int) (err os.Error) {
+	if l < def.MinLen || (def.MaxLen > 0 && l > def.MaxLen) {
+		err = os.NewError("Optional param " + def.Name + ": Invalid length")
+	}
+	return
+}
+
+// Encode an optional param value, unknown tags are encoded by the value type
+func encodeParam(tag SMPPOptionalParamTag, val interface{}) (p []byte, err os.Error) {
+	def, ok := LookupParam(tag)
+	if !ok {
+		switch v := val.(type) {
+			default:
+				err = os.NewError("Invalid optional param format")
+			case string:
+				p = []byte(v)
+			case []byte:
+				p = v
+			case bool:
+				p = make([]byte, 1)
+				if v {
+					p[0] = 0x01
+				}
+			case uint8:
+				p = packUint(uint64(v), 1)
+			case uint16:
+				p = packUint(uint64(v), 2)
+			case uint32:
+				p = packUint(uint64(v), 4)
+			case uint64:
+				p = packUint(v, 8)
+		}
+		return
+	}
+	switch def.Type {
+		case TLV_INT1, TLV_INT2, TLV_INT4:
+			var n uint64
+			n, err = paramUint(val)
+			if err != nil {
+				return
+			}
+			if n >> uint(def.MaxLen * 8) != 0 {
+				err = os.NewError("Optional param " + def.Name + ": Value out of range")
+				return
+			}
+			p = packUint(n, uint8(def.MaxLen))
+		case TLV_OCTET:
+			switch v := val.(type) {
+				default:
+					err = os.NewError("Optional param " + def.Name + ": Invalid format")
+					return
+				case nil:
+					p = []byte{}
+				case []byte:
+					p = v
+			}
+		// C-octet strings are null terminated on the wire
+		case TLV_COCTET:
+			var v string
+			switch t := val.(type) {
+				default:
+					err = os.NewError("Optional param " + def.Name + ": Invalid format")
+					return
+				case string:
+					v = t
+				case []byte:
+					v = string(t)
+			}
+			if strings.Index(v, "\x00") != -1 {
+				err = os.NewError("Optional param " + def.Name + ": C-octet string contains null")
+				return
+			}
+			p = []byte(v + "\x00")
+	}
+	err = def.checkLen(len(p))
+	return
+}
+
+// Get an integer param value
+func paramUint(val interface{}) (n uint64, err os.Error) {
+	switch v := val.(type) {
+		default:
+			err = os.NewError("Invalid optional param format")
+		case bool:
+			if v {
+				n = 1
+			}
+		case uint8:
+			n = uint64(v)
+		case uint16:
+			n = uint64(v)
+		case uint32:
+			n = uint64(v)
+		case uint64:
+			n = v
+		case int:
+			if v < 0 {
+				err = os.NewError("Invalid optional param format")
+			}
+			n = uint64(v)
+	}
+	return
+}
+
+// Decode an optional param value, unknown tags and values with an invalid length are kept as raw bytes
+// Lengths are only enforced on encode so a non-conforming SMSC doesn't fail the whole PDU
+func decodeParam(tag SMPPOptionalParamTag, vp []byte) (value interface{}) {
+	def, ok := LookupParam(tag)
+	if !ok || def.checkLen(len(vp)) != nil {
+		return vp
+	}
+	if len(vp) == 0 {
+		return
+	}
+	switch def.Type {
+		case TLV_INT1:
+			value = uint8(vp[0])
+		case TLV_INT2:
+			value = uint16(unpackUint(vp))
+		case TLV_INT4:
+			value = uint32(unpackUint(vp))
+		case TLV_OCTET:
+			value = vp
+		// Null terminator is not part of the value
+		case TLV_COCTET:
+			if vp[len(vp) - 1] == 0x00 {
+				vp = vp[0:len(vp) - 1]
+			}
+			value = string(vp)
+	}
+	return
+}
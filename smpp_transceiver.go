@@ -4,7 +4,18 @@
 // Further information on this license can be found here: http://creativecommons.org/licenses/by-sa/2.0/uk/
 package smpp
 
-// Transceiver type
+import (
+	"os"
+)
+
+// Transceiver type, offers all transmitter operations while receiving messages on the same connection
+// Responses and inbound requests are separated by a single reader started when bound
 type Transceiver struct {
-	smpp
+	Transmitter
+}
+
+// Receive messages, blocks until the connection is unbound or an error occurs (or closed if reconnecting)
+// Messages may be submitted from other goroutines while receiving
+func (trx *Transceiver) Receive(handler DeliverSMHandler) (err os.Error) {
+	return trx.receive(handler)
 }
@@ -6,8 +6,6 @@ package smpp
 
 import (
 	"os"
-	"reflect"
-	"fmt"
 )
 
 // Transmitter type
@@ -16,7 +14,40 @@ type Transmitter struct {
 }
 
 // Submit SM
-func (tx *Transmitter) SubmitSM(dest, msg string, params Params, optional ...OptParams) (sequence uint32, msgId string, err os.Error) {
+func (tx *Transmitter) SubmitSM(dest, msg string, params *SubmitSMParams, optional ...OptParams) (sequence uint32, msgId string, err os.Error) {
+	// Create PDU
+	pdu, err := tx.submitSM(dest, msg, params, optional...)
+	if err != nil {
+		return
+	}
+	// If async send the PDU and return the sequence number, else get the response
+	if tx.async {
+		sequence, err = tx.sendRequest(pdu)
+		return
+	}
+	rpdu, err := tx.request(pdu, CMD_SUBMIT_SM_RESP)
+	if err != nil {
+		return
+	}
+	s := rpdu.GetStruct()
+	msgId = s.(PDUSubmitSMResp).MessageId
+	return
+}
+
+// Submit SM asynchronously, the response is sent on the returned channel once received
+func (tx *Transmitter) SubmitSMAsync(dest, msg string, params *SubmitSMParams, optional ...OptParams) (res <-chan *Response, err os.Error) {
+	// Create PDU
+	pdu, err := tx.submitSM(dest, msg, params, optional...)
+	if err != nil {
+		return
+	}
+	// Send via the pipeline
+	res, err = tx.sendAsync(pdu)
+	return
+}
+
+// Create Submit SM PDU
+func (tx *Transmitter) submitSM(dest, msg string, params *SubmitSMParams, optional ...OptParams) (pdu *PDUSubmitSM, err os.Error) {
 	// Check connected and bound
 	if !tx.connected || !tx.bound {
 		err = os.NewError("SubmitSM: A bound connection is required to submit a message")
@@ -27,103 +58,100 @@ func (tx *Transmitter) SubmitSM(dest, msg string, params Params, optional ...Opt
 		err = os.NewError("SubmitSM: A destination number is required and should not be null")
 		return
 	}
-	// Merge params with defaults
-	allParams := mergeParams(params, defaultsSubmitSM)
-	// Increment sequence number
-	tx.sequence ++
+	// Use defaults if no params
+	if params == nil {
+		params = NewSubmitSMParams()
+	}
+	// Validate params
+	err = params.Validate()
+	if err != nil {
+		return
+	}
+	if err = checkCString("DestAddr", dest, MAX_ADDR_LEN); err != nil {
+		return
+	}
+	// Encode message for the data coding
+	coding := tx.messageCoding(msg, params.DataCoding)
+	sm, err := encodeMessage(msg, coding)
+	if err != nil {
+		return
+	}
+	return tx.buildSubmitSM(dest, sm, coding, params, optional...)
+}
+
+// Build Submit SM PDU from an encoded short message
+func (tx *Transmitter) buildSubmitSM(dest, sm string, coding SMPPDataCoding, params *SubmitSMParams, optional ...OptParams) (pdu *PDUSubmitSM, err os.Error) {
+	if err = checkShortMessage("ShortMessage", sm); err != nil {
+		return
+	}
 	// PDU header
 	hdr := new(PDUHeader)
-	hdr.CmdLength = 34
 	hdr.CmdId     = CMD_SUBMIT_SM
 	hdr.CmdStatus = STATUS_ESME_ROK
-	hdr.Sequence  = tx.sequence
-	// Mising params cause panic, this provides a clean error/exit
-	paramOK := false
-	defer func() {
-		if !paramOK && recover() != nil {
-			err = os.NewError("SubmitSM: Panic, invalid params")
-			return
-		}
-	}()
 	// Create new PDU
-	pdu := new(PDUSubmitSM)
+	pdu = new(PDUSubmitSM)
 	// Populate params
-	pdu.ServiceType     = allParams["serviceType"].(string)
-	pdu.SourceAddrTon   = allParams["sourceAddrTon"].(SMPPTypeOfNumber)
-	pdu.SourceAddrNpi   = allParams["sourceAddrNpi"].(SMPPNumericPlanIndicator)
-	pdu.SourceAddr      = allParams["sourceAddr"].(string)
-	pdu.DestAddrTon     = allParams["destAddrTon"].(SMPPTypeOfNumber)
-	pdu.DestAddrNpi     = allParams["destAddrNpi"].(SMPPNumericPlanIndicator)
+	pdu.ServiceType     = params.ServiceType
+	pdu.SourceAddrTon   = params.SourceAddrTon
+	pdu.SourceAddrNpi   = params.SourceAddrNpi
+	pdu.SourceAddr      = params.SourceAddr
+	pdu.DestAddrTon     = params.DestAddrTon
+	pdu.DestAddrNpi     = params.DestAddrNpi
 	pdu.DestAddr        = dest
-	pdu.EsmClass        = allParams["esmClass"].(SMPPEsmClassESME)
-	pdu.ProtocolId      = allParams["protocolId"].(uint8)
-	pdu.PriorityFlag    = allParams["priorityFlag"].(SMPPPriority)
-	pdu.SchedDelTime    = allParams["schedDelTime"].(string)
-	pdu.ValidityPeriod  = allParams["validityPeriod"].(string)
-	pdu.RegDelivery     = allParams["regDelivery"].(SMPPDelivery)
-	pdu.ReplaceFlag     = allParams["replaceFlag"].(uint8)
-	pdu.DataCoding      = allParams["dataCoding"].(SMPPDataCoding)
-	pdu.SmDefaultMsgId  = allParams["smDefaultMsgId"].(uint8)
-	pdu.SmLength        = uint8(len(msg))
-	pdu.ShortMessage    = msg
-	// Add length of strings to pdu length
-	hdr.CmdLength += uint32(len(pdu.ServiceType))
-	hdr.CmdLength += uint32(len(pdu.SourceAddr))
-	hdr.CmdLength += uint32(len(pdu.DestAddr))
-	hdr.CmdLength += uint32(len(pdu.SchedDelTime))
-	hdr.CmdLength += uint32(len(pdu.ValidityPeriod))
-	hdr.CmdLength += uint32(len(pdu.ShortMessage))
-	// Calculate size of optional params
-	if len(optional) > 0 && len(optional[0]) > 0 {
+	pdu.EsmClass        = params.EsmClass
+	pdu.ProtocolId      = params.ProtocolId
+	pdu.PriorityFlag    = params.PriorityFlag
+	pdu.SchedDelTime    = params.SchedDelTime
+	pdu.ValidityPeriod  = params.ValidityPeriod
+	pdu.RegDelivery     = params.RegDelivery
+	pdu.ReplaceFlag     = params.ReplaceFlag
+	pdu.DataCoding      = coding
+	pdu.SmDefaultMsgId  = params.SmDefaultMsgId
+	pdu.ShortMessage    = sm
+	// Optional params
+	if len(optional) > 0 {
 		pdu.Optional = optional[0]
-		for _, val := range optional[0] {
-			v := reflect.NewValue(val)
-			switch t := v.(type) {
-				default:
-					err = os.NewError("SubmitSM: Invalid optional param format")
-					return
-				case *reflect.StringValue:
-					hdr.CmdLength += uint32(len(val.(string)))
-					pdu.OptionalLen += uint32(len(val.(string)))
-				case *reflect.Uint8Value:
-					hdr.CmdLength ++
-					pdu.OptionalLen ++
-				case *reflect.Uint16Value:
-					hdr.CmdLength += 2
-					pdu.OptionalLen += 2
-				case *reflect.Uint32Value:
-					hdr.CmdLength += 4
-					pdu.OptionalLen += 4
-			}
-			// Add 4 bytes for optional param header
-			hdr.CmdLength += 4
-			pdu.OptionalLen += 4
-		}
 	}
-	// Params were fine 'disable' the recover
-	paramOK = true
-	// Send PDU
 	pdu.setHeader(hdr)
-	err = pdu.write(tx.writer)
+	return
+}
+
+// Submit Multi
+func (tx *Transmitter) SubmitMulti(destNum, destList []string, msg string, params *SubmitSMParams, optional ...OptParams) (sequence uint32, msgId string, unsuccess []string, err os.Error) {
+	// Create PDU
+	pdu, err := tx.submitMulti(destNum, destList, msg, params, optional...)
 	if err != nil {
 		return
 	}
-	// If not async get the response
+	// If async send the PDU and return the sequence number, else get the response
 	if tx.async {
-		sequence = tx.sequence
-	} else {
-		rpdu, err := tx.GetResp(CMD_SUBMIT_SM_RESP, tx.sequence)
-		if err != nil {
-			return
-		}
-		s := rpdu.GetStruct()
-		msgId = s.(PDUSubmitSMResp).MessageId
+		sequence, err = tx.sendRequest(pdu)
+		return
+	}
+	rpdu, err := tx.request(pdu, CMD_SUBMIT_MULTI_RESP)
+	if err != nil {
+		return
 	}
+	s := rpdu.GetStruct()
+	msgId     = s.(PDUSubmitMultiResp).MessageId
+	unsuccess = s.(PDUSubmitMultiResp).Unsuccess
 	return
 }
 
-// Submit Multi
-func (tx *Transmitter) SubmitMulti(destNum, destList []string, msg string, params Params, optional ...OptParams) (sequence uint32, msgId string, unsuccess []string, err os.Error) {
+// Submit Multi asynchronously, the response is sent on the returned channel once received
+func (tx *Transmitter) SubmitMultiAsync(destNum, destList []string, msg string, params *SubmitSMParams, optional ...OptParams) (res <-chan *Response, err os.Error) {
+	// Create PDU
+	pdu, err := tx.submitMulti(destNum, destList, msg, params, optional...)
+	if err != nil {
+		return
+	}
+	// Send via the pipeline
+	res, err = tx.sendAsync(pdu)
+	return
+}
+
+// Create Submit Multi PDU
+func (tx *Transmitter) submitMulti(destNum, destList []string, msg string, params *SubmitSMParams, optional ...OptParams) (pdu *PDUSubmitMulti, err os.Error) {
 	// Check connected and bound
 	if !tx.connected || !tx.bound {
 		err = os.NewError("SubmitMulti: A bound connection is required to submit a message")
@@ -134,112 +162,223 @@ func (tx *Transmitter) SubmitMulti(destNum, destList []string, msg string, param
 		err = os.NewError("SubmitMulti: At least 1 destination number or list is required")
 		return
 	}
-	// Merge params with defaults
-	allParams := mergeParams(params, defaultsSubmitMulti)
-	// Increment sequence number
-	tx.sequence ++
+	// Use defaults if no params
+	if params == nil {
+		params = NewSubmitSMParams()
+	}
+	// Validate params
+	err = params.Validate()
+	if err != nil {
+		return
+	}
+	for _, num := range destNum {
+		if err = checkCString("DestAddr", num, MAX_ADDR_LEN); err != nil {
+			return
+		}
+	}
+	for _, list := range destList {
+		if err = checkCString("DestList", list, MAX_DL_NAME_LEN); err != nil {
+			return
+		}
+	}
+	if len(destNum) + len(destList) > 254 {
+		err = &ParamError{"NumOfDests", "exceeds 254 destinations"}
+		return
+	}
+	// Encode message for the data coding
+	coding := tx.messageCoding(msg, params.DataCoding)
+	sm, err := encodeMessage(msg, coding)
+	if err != nil {
+		return
+	}
+	if err = checkShortMessage("ShortMessage", sm); err != nil {
+		return
+	}
 	// PDU header
 	hdr := new(PDUHeader)
-	hdr.CmdLength = 32
 	hdr.CmdId     = CMD_SUBMIT_MULTI
 	hdr.CmdStatus = STATUS_ESME_ROK
-	hdr.Sequence  = tx.sequence
-	// Mising params cause panic, this provides a clean error/exit
-	paramOK := false
-	defer func() {
-		if !paramOK && recover() != nil {
-			err = os.NewError("SubmitMulti: Panic, invalid params")
-			return
-		}
-	}()
 	// Create new PDU
-	pdu := new(PDUSubmitMulti)
+	pdu = new(PDUSubmitMulti)
 	// Populate params
-	pdu.ServiceType     = allParams["serviceType"].(string)
-	pdu.SourceAddrTon   = allParams["sourceAddrTon"].(SMPPTypeOfNumber)
-	pdu.SourceAddrNpi   = allParams["sourceAddrNpi"].(SMPPNumericPlanIndicator)
-	pdu.SourceAddr      = allParams["sourceAddr"].(string)
-	pdu.NumOfDests      = uint8(len(destNum) + len(destList))
-	pdu.DestAddrTon     = allParams["destAddrTon"].(SMPPTypeOfNumber)
-	pdu.DestAddrNpi     = allParams["destAddrNpi"].(SMPPNumericPlanIndicator)
+	pdu.ServiceType     = params.ServiceType
+	pdu.SourceAddrTon   = params.SourceAddrTon
+	pdu.SourceAddrNpi   = params.SourceAddrNpi
+	pdu.SourceAddr      = params.SourceAddr
+	pdu.DestAddrTon     = params.DestAddrTon
+	pdu.DestAddrNpi     = params.DestAddrNpi
 	pdu.DestAddrs       = destNum
 	pdu.DestLists       = destList
-	pdu.EsmClass        = allParams["esmClass"].(SMPPEsmClassESME)
-	pdu.ProtocolId      = allParams["protocolId"].(uint8)
-	pdu.PriorityFlag    = allParams["priorityFlag"].(SMPPPriority)
-	pdu.SchedDelTime    = allParams["schedDelTime"].(string)
-	pdu.ValidityPeriod  = allParams["validityPeriod"].(string)
-	pdu.RegDelivery     = allParams["regDelivery"].(SMPPDelivery)
-	pdu.ReplaceFlag     = allParams["replaceFlag"].(uint8)
-	pdu.DataCoding      = allParams["dataCoding"].(SMPPDataCoding)
-	pdu.SmDefaultMsgId  = allParams["smDefaultMsgId"].(uint8)
-	pdu.SmLength        = uint8(len(msg))
-	pdu.ShortMessage    = msg
-	// Add length of strings to pdu length
-	hdr.CmdLength += uint32(len(pdu.ServiceType))
-	hdr.CmdLength += uint32(len(pdu.SourceAddr))
-	hdr.CmdLength += uint32(len(pdu.SchedDelTime))
-	hdr.CmdLength += uint32(len(pdu.ValidityPeriod))
-	hdr.CmdLength += uint32(len(pdu.ShortMessage))
-	// Add length of any numbers to the pdu length
-	if len(destNum) > 0 {
-		for _, num := range destNum {
-			hdr.CmdLength += uint32(len(num)) + 4
-		}
+	pdu.EsmClass        = params.EsmClass
+	pdu.ProtocolId      = params.ProtocolId
+	pdu.PriorityFlag    = params.PriorityFlag
+	pdu.SchedDelTime    = params.SchedDelTime
+	pdu.ValidityPeriod  = params.ValidityPeriod
+	pdu.RegDelivery     = params.RegDelivery
+	pdu.ReplaceFlag     = params.ReplaceFlag
+	pdu.DataCoding      = coding
+	pdu.SmDefaultMsgId  = params.SmDefaultMsgId
+	pdu.ShortMessage    = sm
+	// Optional params
+	if len(optional) > 0 {
+		pdu.Optional = optional[0]
 	}
-	// Add length of any list names to the pdu length
-	if len(destList) > 0 {
-		for _, list := range destList {
-			hdr.CmdLength += uint32(len(list)) + 2
-		}
+	pdu.setHeader(hdr)
+	return
+}
+
+// Query SM, returns the message state, final date and error code in the response
+func (tx *Transmitter) QuerySM(msgId string, params *QuerySMParams) (sequence uint32, resp *PDUQuerySMResp, err os.Error) {
+	// Check connected and bound
+	if !tx.connected || !tx.bound {
+		err = os.NewError("QuerySM: A bound connection is required to query a message")
+		return
 	}
-	// Calculate size of optional params
-	if len(optional) > 0 && len(optional[0]) > 0 {
-		pdu.Optional = optional[0]
-		for _, val := range optional[0] {
-			v := reflect.NewValue(val)
-			switch t := v.(type) {
-				default:
-					err = os.NewError("SubmitMulti: Invalid optional param format")
-					return
-				case *reflect.StringValue:
-					hdr.CmdLength += uint32(len(val.(string)))
-					pdu.OptionalLen += uint32(len(val.(string)))
-				case *reflect.Uint8Value:
-					hdr.CmdLength ++
-					pdu.OptionalLen ++
-				case *reflect.Uint16Value:
-					hdr.CmdLength += 2
-					pdu.OptionalLen += 2
-				case *reflect.Uint32Value:
-					hdr.CmdLength += 4
-					pdu.OptionalLen += 4
-			}
-			// Add 4 bytes for optional param header
-			hdr.CmdLength += 4
-			pdu.OptionalLen += 4
-		}
+	// Check message id
+	if msgId == "" {
+		err = os.NewError("QuerySM: A message id is required and should not be null")
+		return
+	}
+	// Use defaults if no params
+	if params == nil {
+		params = NewQuerySMParams()
+	}
+	// Validate params
+	err = params.Validate()
+	if err != nil {
+		return
+	}
+	if err = checkCString("MessageId", msgId, MAX_MESSAGE_ID_LEN); err != nil {
+		return
+	}
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_QUERY_SM
+	hdr.CmdStatus = STATUS_ESME_ROK
+	// Create new PDU
+	pdu := new(PDUQuerySM)
+	// Populate params
+	pdu.MessageId     = tx.messageId(msgId)
+	pdu.SourceAddrTon = params.SourceAddrTon
+	pdu.SourceAddrNpi = params.SourceAddrNpi
+	pdu.SourceAddr    = params.SourceAddr
+	pdu.setHeader(hdr)
+	// If async send the PDU and return the sequence number, else get the response
+	if tx.async {
+		sequence, err = tx.sendRequest(pdu)
+		return
+	}
+	rpdu, err := tx.request(pdu, CMD_QUERY_SM_RESP)
+	if err != nil {
+		return
+	}
+	resp = rpdu.(*PDUQuerySMResp)
+	return
+}
+
+// Cancel SM, by message id or by source and destination address (and optionally service type) if the message id is null
+func (tx *Transmitter) CancelSM(msgId string, params *CancelSMParams) (sequence uint32, err os.Error) {
+	// Check connected and bound
+	if !tx.connected || !tx.bound {
+		err = os.NewError("CancelSM: A bound connection is required to cancel a message")
+		return
+	}
+	// Use defaults if no params
+	if params == nil {
+		params = NewCancelSMParams()
+	}
+	// Check message id or source and destination
+	if msgId == "" && (params.SourceAddr == "" || params.DestAddr == "") {
+		err = os.NewError("CancelSM: A message id or source and destination address is required")
+		return
+	}
+	// Validate params
+	err = params.Validate()
+	if err != nil {
+		return
 	}
-	// Params were fine 'disable' the recover
-	paramOK = true
-	// Send PDU
+	if err = checkCString("MessageId", msgId, MAX_MESSAGE_ID_LEN); err != nil {
+		return
+	}
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_CANCEL_SM
+	hdr.CmdStatus = STATUS_ESME_ROK
+	// Create new PDU
+	pdu := new(PDUCancelSM)
+	// Populate params
+	pdu.ServiceType   = params.ServiceType
+	pdu.MessageId     = tx.messageId(msgId)
+	pdu.SourceAddrTon = params.SourceAddrTon
+	pdu.SourceAddrNpi = params.SourceAddrNpi
+	pdu.SourceAddr    = params.SourceAddr
+	pdu.DestAddrTon   = params.DestAddrTon
+	pdu.DestAddrNpi   = params.DestAddrNpi
+	pdu.DestAddr      = params.DestAddr
 	pdu.setHeader(hdr)
-	err = pdu.write(tx.writer)
+	// If async send the PDU and return the sequence number, else get the response
+	if tx.async {
+		sequence, err = tx.sendRequest(pdu)
+		return
+	}
+	_, err = tx.request(pdu, CMD_CANCEL_SM_RESP)
+	return
+}
+
+// Replace SM, replaces the text and/or schedule of a pending message
+func (tx *Transmitter) ReplaceSM(msgId, msg string, params *ReplaceSMParams) (sequence uint32, err os.Error) {
+	// Check connected and bound
+	if !tx.connected || !tx.bound {
+		err = os.NewError("ReplaceSM: A bound connection is required to replace a message")
+		return
+	}
+	// Check message id
+	if msgId == "" {
+		err = os.NewError("ReplaceSM: A message id is required and should not be null")
+		return
+	}
+	// Use defaults if no params
+	if params == nil {
+		params = NewReplaceSMParams()
+	}
+	// Validate params
+	err = params.Validate()
 	if err != nil {
 		return
 	}
-	// If not async get the response
+	if err = checkCString("MessageId", msgId, MAX_MESSAGE_ID_LEN); err != nil {
+		return
+	}
+	// Encode message with the coding of the original message
+	sm, err := encodeMessage(msg, params.DataCoding)
+	if err != nil {
+		return
+	}
+	if err = checkShortMessage("ShortMessage", sm); err != nil {
+		return
+	}
+	// PDU header
+	hdr := new(PDUHeader)
+	hdr.CmdId     = CMD_REPLACE_SM
+	hdr.CmdStatus = STATUS_ESME_ROK
+	// Create new PDU
+	pdu := new(PDUReplaceSM)
+	// Populate params
+	pdu.MessageId      = tx.messageId(msgId)
+	pdu.SourceAddrTon  = params.SourceAddrTon
+	pdu.SourceAddrNpi  = params.SourceAddrNpi
+	pdu.SourceAddr     = params.SourceAddr
+	pdu.SchedDelTime   = params.SchedDelTime
+	pdu.ValidityPeriod = params.ValidityPeriod
+	pdu.RegDelivery    = params.RegDelivery
+	pdu.SmDefaultMsgId = params.SmDefaultMsgId
+	pdu.ShortMessage   = sm
+	pdu.setHeader(hdr)
+	// If async send the PDU and return the sequence number, else get the response
 	if tx.async {
-		sequence = tx.sequence
-	} else {
-		rpdu, err := tx.GetResp(CMD_SUBMIT_MULTI_RESP, tx.sequence)
-		if err != nil {
-		fmt.Printf("Result: %#v, %#v\n", rpdu, err)
-			return 
-		}
-		s := rpdu.GetStruct()
-		msgId     = s.(PDUSubmitMultiResp).MessageId
-		unsuccess = s.(PDUSubmitMultiResp).Unsuccess
+		sequence, err = tx.sendRequest(pdu)
+		return
 	}
+	_, err = tx.request(pdu, CMD_REPLACE_SM_RESP)
 	return
 }